@@ -0,0 +1,325 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// the possible version bumps, in order of increasing significance
+var bumps = []string{"patch", "minor", "major"}
+
+// maxBump returns the more significant of the given version bumps.
+func maxBump(a, b string) string {
+	if slices.Index(bumps, b) > slices.Index(bumps, a) {
+		return b
+	}
+	return a
+}
+
+// DecideBump returns the version bump ("patch", "minor", or "major") that
+// should be used for the next release of the given repository, along with
+// the reason for it. A bump specified for the repository in the config
+// Bump map takes precedence; otherwise, it uses [InferBump]. Breaking
+// changes in repositories with a major version of zero result in a minor
+// bump, as is conventional for Go modules.
+func DecideBump(ctx context.Context, c *Config, rep *Repository) (bump string, reason string, err error) {
+	if b, ok := c.Bump[rep.Name]; ok {
+		if !slices.Contains(bumps, b) {
+			return "", "", fmt.Errorf("invalid bump %q for repository %q (must be patch, minor, or major)", b, rep.Name)
+		}
+		return b, "specified", nil
+	}
+	if rep.Version == "" {
+		return "patch", "initial release", nil
+	}
+	bump, reason, err = InferBump(ctx, rep, rep.Version)
+	if err != nil {
+		return "", "", err
+	}
+	if bump == "major" && semver.Major(rep.Version) == "v0" {
+		return "minor", reason + ", v0", nil
+	}
+	return bump, reason, nil
+}
+
+// InferBump infers the version bump ("patch", "minor", or "major") needed
+// for the changes in the given repository since the given version tag, based on
+// both the conventional commit messages (https://www.conventionalcommits.org)
+// of the commits since the tag and the differences in the exported API of the
+// Go packages in the repository between the tag and the current files. It also
+// returns the reason for the bump.
+func InferBump(ctx context.Context, rep *Repository, tag string) (bump string, reason string, err error) {
+	cbump, err := commitBump(ctx, rep, tag)
+	if err != nil {
+		return "", "", err
+	}
+	old, err := exportedAPI(ctx, rep, tag)
+	if err != nil {
+		return "", "", err
+	}
+	cur, err := exportedAPI(ctx, rep, "")
+	if err != nil {
+		return "", "", err
+	}
+	abump := apiBump(old, cur)
+	bump = maxBump(cbump, abump)
+	switch {
+	case bump == "patch":
+		reason = "no API changes"
+	case abump == bump && abump == "major":
+		reason = "breaking API changes"
+	case abump == bump:
+		reason = "API additions"
+	case bump == "major":
+		reason = "breaking change commits"
+	default:
+		reason = "feature commits"
+	}
+	return bump, reason, nil
+}
+
+// conventionalCommitRegexp matches the header of a conventional commit
+// message, with the type as the first group and an optional breaking
+// change indicator as the second group.
+var conventionalCommitRegexp = regexp.MustCompile(`^(\w+)(?:\([^)]*\))?(!)?:`)
+
+// parseCommitMessage parses the given conventional commit message, returning
+// its type in lowercase (eg: feat), or "" if it is not a conventional commit,
+// and whether it indicates a breaking change, either with a ! after the type
+// or with a BREAKING CHANGE footer.
+func parseCommitMessage(msg string) (typ string, breaking bool) {
+	breaking = strings.Contains(msg, "BREAKING CHANGE:") || strings.Contains(msg, "BREAKING-CHANGE:")
+	m := conventionalCommitRegexp.FindStringSubmatch(msg)
+	if m == nil {
+		return "", breaking
+	}
+	return strings.ToLower(m[1]), breaking || m[2] == "!"
+}
+
+// commitBump returns the version bump indicated by the conventional
+// commit messages of the commits in the given repository since the given tag.
+func commitBump(ctx context.Context, rep *Repository, tag string) (string, error) {
+	msgs, err := GitFrom(ctx).Log(ctx, rep.Dir, tag)
+	if err != nil {
+		return "", fmt.Errorf("error getting commit messages since %q for repository %q: %w", tag, rep.Name, err)
+	}
+	bump := "patch"
+	for _, msg := range msgs {
+		typ, breaking := parseCommitMessage(msg)
+		if breaking {
+			return "major", nil
+		}
+		if typ == "feat" {
+			bump = "minor"
+		}
+	}
+	return bump, nil
+}
+
+// apiBump returns the version bump needed to go from the
+// given old exported API to the given current exported API.
+func apiBump(old, cur map[string]string) string {
+	bump := "patch"
+	for k, v := range old {
+		cv, ok := cur[k]
+		if !ok || cv != v {
+			return "major"
+		}
+	}
+	for k := range cur {
+		if _, ok := old[k]; !ok {
+			bump = "minor"
+		}
+	}
+	return bump
+}
+
+// exportedAPI returns the exported API of the non-main Go packages in the
+// given repository at the given Git revision, or in the current files on the
+// local filesystem if the revision is "". The API is represented as a map from
+// qualified identifiers (eg: dir.Type.Method) to their signatures.
+func exportedAPI(ctx context.Context, rep *Repository, rev string) (map[string]string, error) {
+	files := map[string][]byte{}
+	if rev == "" {
+		err := fs.WalkDir(os.DirFS(rep.Dir), ".", func(fpath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if skipModuleDir(fpath, d) || d.Name() == "vendor" {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if !isAPIFile(fpath) {
+				return nil
+			}
+			b, err := os.ReadFile(filepath.Join(rep.Dir, filepath.FromSlash(fpath)))
+			if err != nil {
+				return err
+			}
+			files[fpath] = b
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading Go files of repository %q: %w", rep.Name, err)
+		}
+	} else {
+		git := GitFrom(ctx)
+		fpaths, err := git.Files(ctx, rep.Dir, rev)
+		if err != nil {
+			return nil, fmt.Errorf("error listing files at %q for repository %q: %w", rev, rep.Name, err)
+		}
+		for _, fpath := range fpaths {
+			if !isAPIFile(fpath) {
+				continue
+			}
+			b, err := git.Show(ctx, rep.Dir, rev, fpath)
+			if err != nil {
+				return nil, fmt.Errorf("error getting %q at %q for repository %q: %w", fpath, rev, rep.Name, err)
+			}
+			files[fpath] = b
+		}
+	}
+
+	api := map[string]string{}
+	fset := token.NewFileSet()
+	for fpath, b := range files {
+		f, err := parser.ParseFile(fset, fpath, b, parser.SkipObjectResolution)
+		if err != nil {
+			// files that don't parse can't contribute to the API
+			continue
+		}
+		if f.Name.Name == "main" {
+			continue
+		}
+		addFileAPI(api, path.Dir(fpath), f)
+	}
+	return api, nil
+}
+
+// isAPIFile returns whether the given slash-separated file path is
+// a Go file that can contribute to the exported API of a package.
+func isAPIFile(fpath string) bool {
+	if !strings.HasSuffix(fpath, ".go") || strings.HasSuffix(fpath, "_test.go") {
+		return false
+	}
+	for _, elem := range strings.Split(path.Dir(fpath), "/") {
+		if elem == "internal" || elem == "testdata" || elem == "vendor" || (elem != "." && (strings.HasPrefix(elem, ".") || strings.HasPrefix(elem, "_"))) {
+			return false
+		}
+	}
+	return true
+}
+
+// addFileAPI adds the exported API of the given file in the
+// given package directory to the given API map.
+func addFileAPI(api map[string]string, dir string, f *ast.File) {
+	// we print with an empty file set so that the positions of the nodes, and
+	// thus the line breaks in the original source, do not affect the output
+	pfset := token.NewFileSet()
+	str := func(node any) string {
+		b := &bytes.Buffer{}
+		printer.Fprint(b, pfset, node)
+		return b.String()
+	}
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+			key := dir + "." + decl.Name.Name
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				recv := receiverName(decl.Recv.List[0].Type)
+				if !ast.IsExported(recv) {
+					continue
+				}
+				key = dir + "." + recv + "." + decl.Name.Name
+			}
+			api[key] = str(decl.Type)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if !spec.Name.IsExported() {
+						continue
+					}
+					api[dir+"."+spec.Name.Name] = str(exportedType(spec.Type))
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						if !name.IsExported() {
+							continue
+						}
+						sig := decl.Tok.String()
+						if spec.Type != nil {
+							sig += " " + str(spec.Type)
+						}
+						api[dir+"."+name.Name] = sig
+					}
+				}
+			}
+		}
+	}
+}
+
+// receiverName returns the name of the type of the given method receiver.
+func receiverName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(expr.X)
+	case *ast.IndexExpr:
+		return receiverName(expr.X)
+	case *ast.IndexListExpr:
+		return receiverName(expr.X)
+	case *ast.Ident:
+		return expr.Name
+	}
+	return ""
+}
+
+// exportedType returns the given type with any unexported struct
+// fields removed, since they are not part of the exported API.
+func exportedType(expr ast.Expr) ast.Expr {
+	st, ok := expr.(*ast.StructType)
+	if !ok {
+		return expr
+	}
+	fields := &ast.FieldList{}
+	for _, field := range st.Fields.List {
+		nf := *field
+		nf.Doc, nf.Comment = nil, nil
+		if len(field.Names) == 0 { // embedded fields are always part of the API
+			fields.List = append(fields.List, &nf)
+			continue
+		}
+		nf.Names = nil
+		for _, name := range field.Names {
+			if name.IsExported() {
+				nf.Names = append(nf.Names, name)
+			}
+		}
+		if len(nf.Names) > 0 {
+			fields.List = append(fields.List, &nf)
+		}
+	}
+	return &ast.StructType{Fields: fields}
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+func TestParseCommitMessage(t *testing.T) {
+	tests := []struct {
+		msg      string
+		typ      string
+		breaking bool
+		group    string
+	}{
+		{"feat: add Hello", "feat", false, "feat"},
+		{"Feat: add Hello", "feat", false, "feat"},
+		{"fix(hello): punctuate greeting", "fix", false, "fix"},
+		{"refactor!: rename Hello", "refactor", true, "breaking"},
+		{"feat(api)!: remove Hello", "feat", true, "breaking"},
+		{"chore: update deps\n\nBREAKING CHANGE: requires go 1.21", "chore", true, "breaking"},
+		{"update readme", "", false, "other"},
+		{"update readme\n\nBREAKING-CHANGE: moved docs", "", true, "breaking"},
+	}
+	for _, test := range tests {
+		typ, breaking := parseCommitMessage(test.msg)
+		if typ != test.typ || breaking != test.breaking {
+			t.Errorf("expected %q to have type %q and breaking %v, but got %q and %v", test.msg, test.typ, test.breaking, typ, breaking)
+		}
+		if group := commitType(test.msg); group != test.group {
+			t.Errorf("expected %q to be in the %q group, but got %q", test.msg, test.group, group)
+		}
+	}
+}
+
+func TestMaxBump(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"patch", "patch", "patch"},
+		{"patch", "minor", "minor"},
+		{"minor", "patch", "minor"},
+		{"minor", "major", "major"},
+		{"major", "patch", "major"},
+	}
+	for _, test := range tests {
+		if got := maxBump(test.a, test.b); got != test.want {
+			t.Errorf("expected maxBump(%q, %q) to be %q, but got %q", test.a, test.b, test.want, got)
+		}
+	}
+}
+
+func TestAPIBump(t *testing.T) {
+	old := map[string]string{"a.Hello": "func() string", "a.Point": "struct {\n\tX\tint\n}"}
+	tests := []struct {
+		name string
+		cur  map[string]string
+		want string
+	}{
+		{"unchanged", map[string]string{"a.Hello": "func() string", "a.Point": "struct {\n\tX\tint\n}"}, "patch"},
+		{"added", map[string]string{"a.Hello": "func() string", "a.Point": "struct {\n\tX\tint\n}", "a.Goodbye": "func()"}, "minor"},
+		{"removed", map[string]string{"a.Hello": "func() string"}, "major"},
+		{"changed", map[string]string{"a.Hello": "func(name string) string", "a.Point": "struct {\n\tX\tint\n}"}, "major"},
+		{"changed and added", map[string]string{"a.Hello": "func() int", "a.Point": "struct {\n\tX\tint\n}", "a.Goodbye": "func()"}, "major"},
+	}
+	for _, test := range tests {
+		if got := apiBump(old, test.cur); got != test.want {
+			t.Errorf("%s: expected bump %q, but got %q", test.name, test.want, got)
+		}
+	}
+	if got := apiBump(map[string]string{}, map[string]string{}); got != "patch" {
+		t.Errorf("expected bump patch for empty APIs, but got %q", got)
+	}
+}
+
+// testAPIFile is the main file of the package in [TestExportedAPI].
+const testAPIFile = `package api
+
+// Name is the name of the package.
+const Name = "api"
+
+var Count int
+
+var hidden int
+
+type Point struct {
+	X, y int
+	Y    int // the Y coordinate
+	fmt.Stringer
+}
+
+func (p *Point) Add(q Point) Point { return *p }
+
+func (p Point) scale() {}
+
+type list[T any] []T
+
+func (l list[T]) Len() int { return len(l) }
+
+type Set[K comparable, V any] map[K]V
+
+func (s Set[K, V]) Has(k K) bool { return false }
+
+func Hello(name string,
+	loud bool) string {
+	return name
+}
+
+func helper() {}
+`
+
+func TestExportedAPI(t *testing.T) {
+	w := newFakeWorkspace(t)
+	w.AddRepository("api", map[string]string{
+		"go.mod":           "module goki.dev/api\n\ngo 1.21\n",
+		"api.go":           testAPIFile,
+		"api_test.go":      "package api\n\nfunc TestHelper() {}\n",
+		"broken.go":        "package api\n\nfunc Broken( {}\n",
+		"sub/sub.go":       "package sub\n\nfunc Sub() {}\n",
+		"internal/x/x.go":  "package x\n\nfunc X() {}\n",
+		"cmd/tool/main.go": "package main\n\nfunc Main() {}\n",
+		"_old/old.go":      "package old\n\nfunc Old() {}\n",
+	})
+	ctx := w.Context()
+	rep := w.Config().LocalRepository("api")
+
+	old, err := exportedAPI(ctx, rep, "v0.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"..Name":      "const",
+		"..Count":     "var int",
+		"..Point":     "struct {\n\tX\tint\n\tY\tint\n\tfmt.Stringer\n}",
+		"..Point.Add": "func(q Point) Point",
+		"..Set":       "map[K]V",
+		"..Set.Has":   "func(k K) bool",
+		"..Hello":     "func(name string, loud bool) string",
+		"sub.Sub":     "func()",
+	}
+	if !maps.Equal(old, want) {
+		t.Errorf("expected API\n%v\nbut got\n%v", want, old)
+	}
+
+	// unexported fields, formatting, and comments are not part of the API
+	w.Commit("api", "feat: add Goodbye", map[string]string{
+		"api.go": strings.NewReplacer("X, y int", "X, y, z int", "// the Y coordinate", "", "name string,\n\tloud bool", "name string, loud bool").Replace(testAPIFile) +
+			"\nfunc Goodbye() {}\n",
+	})
+	cur, err := exportedAPI(ctx, rep, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := apiBump(old, cur); got != "minor" {
+		t.Errorf("expected only API additions, but got bump %q from\n%v\nto\n%v", got, old, cur)
+	}
+	local, err := exportedAPI(ctx, rep, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !maps.Equal(local, cur) {
+		t.Errorf("expected API of local files\n%v\nto match API at HEAD\n%v", local, cur)
+	}
+
+	w.Commit("api", "fix: make Hello quiet", map[string]string{
+		"api.go": strings.Replace(testAPIFile, "name string,\n\tloud bool", "name string", 1),
+	})
+	cur, err = exportedAPI(ctx, rep, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := apiBump(old, cur); got != "major" {
+		t.Errorf("expected a breaking API change, but got bump %q", got)
+	}
+}
+
+func TestDecideBump(t *testing.T) {
+	w := newFakeWorkspace(t)
+	goMod := map[string]string{"go.mod": "module goki.dev/api\n\ngo 1.21\n"}
+	w.AddRepository("v0", goMod)
+	w.AddRepository("v1", goMod)
+	w.Git.Repositories["v1"].Tags = map[string]int{"v1.2.3": 0}
+	w.AddRepository("feat", goMod)
+	w.AddRepository("fix", goMod)
+	for _, name := range []string{"v0", "v1"} {
+		w.Commit(name, "feat!: drop support for old configs", map[string]string{"config.go": "package api\n"})
+	}
+	w.Commit("feat", "feat: add Hello", map[string]string{"hello.go": "package api\n\nfunc Hello() {}\n"})
+	w.Commit("fix", "fix: typo", map[string]string{"README.md": "# api\n"})
+
+	c := w.Config()
+	c.Bump = map[string]string{"fix": "major"}
+	ctx := w.Context()
+	tests := []struct {
+		name    string
+		version string
+		bump    string
+		reason  string
+	}{
+		{"v0", "v0.1.0", "minor", "breaking change commits, v0"},
+		{"v1", "v1.2.3", "major", "breaking change commits"},
+		{"feat", "v0.1.0", "minor", "API additions"},
+		{"fix", "v0.1.0", "major", "specified"},
+		{"v0", "", "patch", "initial release"},
+	}
+	for _, test := range tests {
+		rep := c.LocalRepository(test.name)
+		rep.Version = test.version
+		bump, reason, err := DecideBump(ctx, c, rep)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if bump != test.bump || reason != test.reason {
+			t.Errorf("%s at %q: expected bump %q because of %q, but got %q because of %q", test.name, test.version, test.bump, test.reason, bump, reason)
+		}
+	}
+
+	c.Bump["fix"] = "huge"
+	rep := c.LocalRepository("fix")
+	rep.Version = "v0.1.0"
+	if _, _, err := DecideBump(ctx, c, rep); err == nil {
+		t.Error("expected an error for an invalid specified bump")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"goki.dev/grows/jsons"
+)
+
+// repositoryCache is the information about a repository list
+// that is stored in a cache file by [saveRepositoryCache].
+type repositoryCache struct {
+
+	// URL is the URL the repositories were fetched from
+	URL string
+
+	// Time is when the repositories were fetched
+	Time time.Time
+
+	// Repositories are the fetched repositories
+	Repositories []*Repository
+}
+
+// repositoryCacheFile returns the path of the cache
+// file for the repository list at the given URL.
+func repositoryCacheFile(url string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting user cache directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(dir, "gsm", "repositories-"+hex.EncodeToString(sum[:8])+".json"), nil
+}
+
+// loadRepositoryCache loads the cached repository list
+// for the given URL, if there is one.
+func loadRepositoryCache(url string) (*repositoryCache, error) {
+	file, err := repositoryCacheFile(url)
+	if err != nil {
+		return nil, err
+	}
+	rc := &repositoryCache{}
+	err = jsons.Open(rc, file)
+	if err != nil {
+		return nil, fmt.Errorf("error loading repository cache file %q: %w", file, err)
+	}
+	if rc.URL != url {
+		return nil, fmt.Errorf("repository cache file %q is for %q, not %q", file, rc.URL, url)
+	}
+	return rc, nil
+}
+
+// saveRepositoryCache saves the given repository list
+// fetched from the given URL to its cache file.
+func saveRepositoryCache(url string, reps []*Repository) error {
+	file, err := repositoryCacheFile(url)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0750)
+	if err != nil {
+		return fmt.Errorf("error making repository cache directory: %w", err)
+	}
+	rc := &repositoryCache{URL: url, Time: time.Now(), Repositories: reps}
+	err = jsons.SaveIndent(rc, file)
+	if err != nil {
+		return fmt.Errorf("error saving repository cache file %q: %w", file, err)
+	}
+	return nil
+}
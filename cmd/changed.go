@@ -7,53 +7,43 @@ package cmd
 //go:generate goki generate
 
 import (
-	"errors"
-	"fmt"
-	"io/fs"
+	"context"
 	"os"
 	"path/filepath"
-	"strings"
-	"sync"
-
-	"goki.dev/grog"
-	"goki.dev/xe"
 )
 
-// Changed concurrently prints all of the repositories that have been changed
-// and need to be updated in version control.
+// Changed concurrently checks which of the repositories in the current directory
+// have been changed and need to be updated in version control, except for those
+// ignored by the workspace manifest and those not selected by the selector flags,
+// and prints a summary of the results for each repository, in which the changed
+// repositories are marked as changed along with the categories of their changes:
+// staged, unstaged, untracked, and unmerged files, commits ahead of the upstream,
+// a branch that has diverged from its upstream, and a branch with no upstream.
 func Changed(c *Config) error { //gti:add
-	wg := sync.WaitGroup{}
-	errs := []error{}
-	fs.WalkDir(os.DirFS("."), ".", func(path string, d fs.DirEntry, err error) error {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if d.Name() != ".git" {
-				return
-			}
-			dir := filepath.Dir(path)
-			out, err := xe.Major().SetDir(dir).Output("git", "diff")
-			if err != nil {
-				errs = append(errs, fmt.Errorf("error getting diff of %q: %w", dir, err))
-				return
-			}
-			if out != "" { // if we have a diff, we have been changed
-				fmt.Println(grog.CmdColor(dir))
-				return
-			}
-			// if we don't have a diff, we also check to make sure we aren't ahead of the remote
-			out, err = xe.Minor().SetDir(dir).Output("git", "status")
-			if err != nil {
-				errs = append(errs, fmt.Errorf("error getting status of %q: %w", dir, err))
-				return
-			}
-			if strings.Contains(out, "Your branch is ahead") { // if we are ahead, we have been changed
-				fmt.Println(grog.CmdColor(dir))
-			}
-		}()
-		return nil
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	reps, err := GitRepositories(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	rs := RunRepositories(ctx, c, reps, func(ctx context.Context, rep *Repository) (Status, error) {
+		changes, err := LocalChanges(ctx, filepath.FromSlash(rep.Dir))
+		if err != nil {
+			return StatusFailed, err
+		}
+		rep.Changes = changes
+		rep.Changed = len(changes) > 0
+		if rep.Changed {
+			return StatusChanged, nil
+		}
+		return StatusOK, nil
 	})
-	wg.Wait()
-	fmt.Println("")
-	return errors.Join(errs...)
+	return rs.Finish(os.Stdout, c.Format)
 }
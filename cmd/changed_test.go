@@ -0,0 +1,53 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"testing"
+)
+
+func TestChangedFakeGit(t *testing.T) {
+	w := newFakeWorkspace(t)
+	files := map[string]string{"README.md": "# test\n"}
+	for _, name := range []string{"ahead", "behind", "clean", "diverged", "unstaged", "untracked"} {
+		w.AddRepository(name, files)
+	}
+	w.Git.Repositories["ahead"].AddCommit("fix: local", map[string]string{"a.txt": "a\n"})
+	w.Git.Remotes[w.Remote("behind")].AddCommit("fix: remote", map[string]string{"b.txt": "b\n"})
+	w.Git.Repositories["diverged"].AddCommit("fix: local", map[string]string{"a.txt": "a\n"})
+	w.Git.Remotes[w.Remote("diverged")].AddCommit("fix: remote", map[string]string{"b.txt": "b\n"})
+	w.Git.Repositories["unstaged"].Files = map[string]string{"README.md": "# changed\n"}
+	w.Git.Repositories["untracked"].Files = map[string]string{"README.md": "# test\n", "new.txt": "new\n"}
+	// the manifest refers to nested repositories by name, not directory
+	w.Git.Remotes[w.Remote("ignored")] = &FakeRepository{}
+	if err := w.Git.Clone(context.Background(), w.Remote("ignored"), "nested/ignored"); err != nil {
+		t.Fatal(err)
+	}
+	w.Git.Repositories["nested/ignored"].Files = map[string]string{"new.txt": "new\n"}
+	writeFiles(t, ".", map[string]string{"gsm.toml": "[[Repositories]]\n  Name = \"ignored\"\n  Ignore = true\n"})
+
+	rs, err := runGsm(t, Changed, w.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatuses(t, rs, map[string]Status{
+		"ahead": StatusChanged, "behind": StatusOK, "clean": StatusOK,
+		"diverged": StatusChanged, "unstaged": StatusChanged, "untracked": StatusChanged,
+	})
+	checkChanges(t, rs, map[string]string{"ahead": "ahead", "diverged": "diverged", "unstaged": "unstaged", "untracked": "untracked"})
+
+	c := w.Config()
+	c.ChangedOnly = true
+	c.Exclude = []string{"ahead"}
+	rs, err = runGsm(t, Changed, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatuses(t, rs, map[string]Status{"diverged": StatusChanged, "unstaged": StatusChanged, "untracked": StatusChanged})
+	if len(w.Git.Calls) != 7 {
+		t.Errorf("expected only the clones to change repositories, but got %v", w.Git.Calls)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// RepositoryChangelog is a preview of what the next release of a
+// repository will contain, computed by [GetChangelogs].
+type RepositoryChangelog struct {
+
+	// Repository is the repository
+	Repository *Repository
+
+	// Version is the latest version of the repository
+	Version string
+
+	// NextVersion is the predicted version of the release
+	NextVersion string
+
+	// Groups are the commits since Version grouped by
+	// conventional commit type, in the order of [changelogTypes]
+	Groups []*ChangelogGroup
+
+	// Bumps are the Goki imports that the release will update
+	// to new versions, in the form module@version
+	Bumps []string
+}
+
+// ChangelogGroup is a group of commits of the same
+// conventional commit type in a [RepositoryChangelog].
+type ChangelogGroup struct {
+
+	// Type is the conventional commit type of the commits (eg: feat),
+	// "breaking" for breaking changes of any type, or "other" for
+	// commits that are not conventional commits
+	Type string
+
+	// Title is the human-readable title of the group (eg: Features)
+	Title string
+
+	// Commits are the commits in the group, from newest to oldest
+	Commits []*GitCommit
+}
+
+// changelogTypes are the known types of changelog groups and their titles,
+// in the order in which they are listed. Other conventional commit types
+// are listed after them in alphabetical order, followed by "other".
+var changelogTypes = [][2]string{
+	{"breaking", "Breaking changes"},
+	{"feat", "Features"},
+	{"fix", "Bug fixes"},
+	{"perf", "Performance improvements"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+	{"test", "Tests"},
+	{"build", "Build system"},
+	{"ci", "Continuous integration"},
+	{"style", "Style"},
+	{"chore", "Chores"},
+	{"revert", "Reverts"},
+}
+
+// Changelog prints a preview of what the next release cycle will ship for each of
+// the repositories that would be released by [Release] and have changed since their
+// latest version tag (see [RepositoryHasChanged]): the commits since that tag, grouped
+// by conventional commit type (https://www.conventionalcommits.org) with the files
+// that each one touched, and the Goki imports that the release will update to new
+// versions (if the update flag is on). It does not change any repositories.
+func Changelog(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	g, comps, err := ReleaseComponents(ctx, c)
+	if err != nil {
+		return err
+	}
+	p, err := PlanRelease(ctx, c, g, comps)
+	if err != nil {
+		return err
+	}
+	cls, err := GetChangelogs(ctx, p)
+	if err != nil {
+		return err
+	}
+	if IsJSON(c.Format) {
+		return WriteJSON(os.Stdout, c.Format, cls, cls)
+	}
+	return PrintChangelogs(os.Stdout, cls)
+}
+
+// GetChangelogs returns the changelogs of the steps of the given release plan
+// for repositories that have changed since their latest version, in release order.
+// Initial releases are not included, since there is no version to compare with.
+func GetChangelogs(ctx context.Context, p *ReleasePlan) ([]*RepositoryChangelog, error) {
+	cls := []*RepositoryChangelog{}
+	for _, step := range p.Steps {
+		rep := step.Repository
+		if !rep.Changed || rep.Version == "" {
+			continue
+		}
+		commits, err := GitFrom(ctx).Commits(ctx, rep.Dir, rep.Version)
+		if err != nil {
+			return nil, fmt.Errorf("error getting commits since %q for repository %q: %w", rep.Version, rep.Name, err)
+		}
+		cls = append(cls, &RepositoryChangelog{
+			Repository:  rep,
+			Version:     rep.Version,
+			NextVersion: step.NextVersion,
+			Groups:      GroupCommits(commits),
+			Bumps:       step.Bumps,
+		})
+	}
+	return cls, nil
+}
+
+// GroupCommits groups the given commits by conventional commit type, as
+// described in [RepositoryChangelog.Groups], keeping their relative order.
+func GroupCommits(commits []*GitCommit) []*ChangelogGroup {
+	groups := map[string]*ChangelogGroup{}
+	for _, gc := range commits {
+		typ := commitType(gc.Message)
+		grp := groups[typ]
+		if grp == nil {
+			grp = &ChangelogGroup{Type: typ, Title: typ}
+			groups[typ] = grp
+		}
+		grp.Commits = append(grp.Commits, gc)
+	}
+	res := []*ChangelogGroup{}
+	for _, ct := range changelogTypes {
+		if grp := groups[ct[0]]; grp != nil {
+			grp.Title = ct[1]
+			res = append(res, grp)
+			delete(groups, ct[0])
+		}
+	}
+	other := groups["other"]
+	delete(groups, "other")
+	for _, typ := range sortedKeys(groups) {
+		res = append(res, groups[typ])
+	}
+	if other != nil {
+		other.Title = "Other changes"
+		res = append(res, other)
+	}
+	return res
+}
+
+// commitType returns the type of the changelog group of the commit
+// with the given message, as described in [ChangelogGroup.Type],
+// using [parseCommitMessage] like [commitBump].
+func commitType(msg string) string {
+	typ, breaking := parseCommitMessage(msg)
+	switch {
+	case breaking:
+		return "breaking"
+	case typ == "":
+		return "other"
+	}
+	return typ
+}
+
+// PrintChangelogs prints the given changelogs to the
+// given writer in a human-readable format.
+func PrintChangelogs(w io.Writer, cls []*RepositoryChangelog) error {
+	if len(cls) == 0 {
+		_, err := fmt.Fprintln(w, "No changes to release")
+		return err
+	}
+	for i, cl := range cls {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s %s -> %s\n", cl.Repository.Name, cl.Version, cl.NextVersion)
+		for _, grp := range cl.Groups {
+			fmt.Fprintf(w, "\n  %s:\n", grp.Title)
+			for _, gc := range grp.Commits {
+				// only the first line of the message fits
+				subject, _, _ := strings.Cut(gc.Message, "\n")
+				fmt.Fprintf(w, "    %s %s\n", gc.Hash[:min(7, len(gc.Hash))], subject)
+				if len(gc.Files) > 0 {
+					fmt.Fprintf(w, "      %s\n", strings.Join(gc.Files, " "))
+				}
+			}
+		}
+		if len(cl.Bumps) > 0 {
+			bumps := slices.Clone(cl.Bumps)
+			slices.Sort(bumps)
+			fmt.Fprintf(w, "\n  Goki import updates:\n")
+			for _, bump := range bumps {
+				fmt.Fprintf(w, "    %s\n", bump)
+			}
+		}
+	}
+	return nil
+}
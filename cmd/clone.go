@@ -5,43 +5,80 @@
 package cmd
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"os"
-	"sync"
-
-	"goki.dev/xe"
+	"slices"
 )
 
-// Clone concurrently clones all of the Goki Go repositories into the current directory.
+// Clone concurrently clones all of the Goki Go repositories from the configured
+// repository source into the current directory, using the configured protocol.
 // It does not clone repositories that the user already has in the current directory.
+// It uses the remote URLs specified in the workspace manifest when they are present,
+// also cloning any repositories that are only listed in the manifest. Clones that fail
+// because of transient network problems are retried. It prints a summary of the
+// results for each repository, in which cloned repositories are marked as changed
+// and existing ones as skipped.
 func Clone(c *Config) error { //gti:add
-	reps, err := GetWebsiteRepositories()
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	src, err := NewRepositorySource(c)
+	if err != nil {
+		return err
+	}
+	sreps, err := src.Repositories(ctx)
 	if err != nil {
 		return fmt.Errorf("error getting repositories: %w", err)
 	}
-	wg := sync.WaitGroup{}
-	wg.Add(len(reps))
-	var errs []error
-	for _, rep := range reps {
-		rep := rep
-		go func() {
-			defer wg.Done()
-			fi, err := os.Stat(rep.Name)
-			if err == nil { // no error means it already exists
-				if fi.IsDir() { // if we already have dir, we don't need to clone
-					return
-				} else {
-					errs = append(errs, fmt.Errorf("file %q (for repository %q) already exists and is not a directory", rep.Name, rep.Title))
-					return
-				}
-			}
-			err = xe.Run("git", "clone", rep.RepositoryURL)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("error cloning repository: %w", err))
-			}
-		}()
+	reps := []*Repository{}
+	for _, rep := range sreps {
+		if m.Ignored(rep.Name) {
+			continue
+		}
+		if remote := m.Remote(rep.Name); remote != "" {
+			rep.RepositoryURL = remote
+		}
+		reps = append(reps, rep)
+	}
+	for _, mr := range m.Repositories {
+		if mr.Ignore || mr.Remote == "" || slices.ContainsFunc(reps, func(rep *Repository) bool { return rep.Name == mr.Name }) {
+			continue
+		}
+		rep := c.NewRepository(mr.Name)
+		rep.RepositoryURL = mr.Remote
+		reps = append(reps, rep)
+	}
+	sel, err := NewSelection(ctx, c, m)
+	if err != nil {
+		return err
 	}
-	wg.Wait()
-	return errors.Join(errs...)
+	reps, err = SelectRepositories(ctx, sel, reps)
+	if err != nil {
+		return err
+	}
+	rs := RunRepositories(ctx, c, reps, func(ctx context.Context, rep *Repository) (Status, error) {
+		rep.Dir = rep.Name
+		fi, err := os.Stat(rep.Name)
+		if err == nil { // no error means it already exists
+			if fi.IsDir() { // if we already have dir, we don't need to clone
+				return StatusSkipped, nil
+			}
+			return StatusFailed, fmt.Errorf("file %q (for repository %q) already exists and is not a directory", rep.Name, rep.Title)
+		}
+		err = Retry(ctx, c, "clone "+rep.Name, func() error {
+			return GitFrom(ctx).Clone(ctx, c.CloneURL(rep), rep.Name)
+		})
+		if err != nil {
+			return StatusFailed, fmt.Errorf("error cloning repository: %w", err)
+		}
+		return StatusChanged, nil
+	})
+	return rs.Finish(os.Stdout, c.Format)
 }
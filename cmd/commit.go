@@ -0,0 +1,135 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Commit commits the changes in each of the changed Git repositories in the current
+// directory (see [LocalChanges]) with the given message, except for those ignored by
+// the workspace manifest and those not selected by the selector flags. It commits
+// all of the changes to tracked files, and also untracked files if the all flag is
+// on. In interactive mode, it asks whether to commit in each changed repository
+// first. It prints a summary of the results for each repository, in which the
+// repositories that were committed in are marked as changed and those that were
+// not chosen or only have untracked files are marked as skipped, along with the
+// categories of the changes that each repository had, like [Changed].
+func Commit(c *Config) error { //gti:add
+	if c.Commit.Message == "" {
+		return errors.New("missing commit message (eg: gsm commit -m \"fix: handle errors\")")
+	}
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	reps, err := GitRepositories(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	changes, err := Map(ctx, c, reps, func(rep *Repository) ([]Change, error) {
+		return LocalChanges(ctx, filepath.FromSlash(rep.Dir))
+	})
+	if err != nil {
+		return err
+	}
+	repChanges := map[*Repository][]Change{}
+	chosen := map[*Repository]bool{}
+	var br *bufio.Reader
+	if c.Commit.Interactive {
+		br = bufio.NewReader(os.Stdin)
+	}
+	for i, rep := range reps {
+		repChanges[rep] = changes[i]
+		rep.Changes = changes[i]
+		rep.Changed = len(changes[i]) > 0
+		if !commitNeeded(changes[i], c.Commit.All) {
+			continue
+		}
+		if !c.Commit.Interactive {
+			chosen[rep] = true
+			continue
+		}
+		chs := make([]string, len(changes[i]))
+		for j, ch := range changes[i] {
+			chs[j] = string(ch)
+		}
+		chosen[rep], err = confirm(br, os.Stderr, fmt.Sprintf("Commit in %s (%s)?", rep.Name, strings.Join(chs, ", ")))
+		if err != nil {
+			return err
+		}
+	}
+	rs := RunRepositories(ctx, c, reps, func(ctx context.Context, rep *Repository) (Status, error) {
+		chs := repChanges[rep]
+		switch {
+		case slices.Contains(chs, ChangeUnmerged):
+			return StatusFailed, errors.New("can not commit with unresolved merge conflicts")
+		case !commitNeeded(chs, c.Commit.All):
+			if slices.Contains(chs, ChangeUntracked) {
+				return StatusSkipped, nil // only untracked files, which need the all flag
+			}
+			return StatusOK, nil
+		case !chosen[rep]:
+			return StatusSkipped, nil
+		}
+		git := GitFrom(ctx)
+		dir := filepath.FromSlash(rep.Dir)
+		if c.Commit.All && slices.Contains(chs, ChangeUntracked) {
+			err := git.Add(ctx, dir, ".")
+			if err != nil {
+				return StatusFailed, fmt.Errorf("error adding untracked files: %w", err)
+			}
+		}
+		err := git.Commit(ctx, dir, c.Commit.Message)
+		if err != nil {
+			return StatusFailed, fmt.Errorf("error committing: %w", err)
+		}
+		return StatusChanged, nil
+	})
+	return rs.Finish(os.Stdout, c.Format)
+}
+
+// commitNeeded returns whether a repository with the given local changes
+// has changes to commit, which includes untracked files if all is true.
+func commitNeeded(changes []Change, all bool) bool {
+	for _, ch := range changes {
+		switch ch {
+		case ChangeStaged, ChangeUnstaged, ChangeUnmerged:
+			return true
+		case ChangeUntracked:
+			if all {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// confirm writes the given yes or no question to the given writer and
+// returns whether the answer read from the given reader is yes. The default
+// answer, which is used for empty and other answers and at the end of the
+// input, is no.
+func confirm(r *bufio.Reader, w io.Writer, question string) (bool, error) {
+	fmt.Fprint(w, question+" [y/N] ")
+	answer, err := r.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("error reading answer: %w", err)
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
@@ -5,22 +5,223 @@
 // Package gsm provides functions for maintaining the source code of Goki itself (Goki Source Management)
 package cmd
 
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/iancoleman/strcase"
+)
+
 // Config contains the configuration information for the GSM tool
 type Config struct { //gti:add
 
+	// Manifest is the path of the workspace manifest file, which
+	// specifies the repositories that each command should skip.
+	// If it does not exist, a default manifest is used.
+	Manifest string `def:"gsm.toml"`
+
+	// Vanity is the vanity import path prefix of the Go modules that
+	// gsm operates on (eg: goki.dev). Only modules and dependencies
+	// with this prefix are treated as Goki modules.
+	Vanity string `def:"goki.dev"`
+
+	// Host is the domain of the Git host of the repositories (eg: github.com)
+	Host string `def:"github.com"`
+
+	// Org is the name of the organization or user on the Git host
+	// that owns the repositories (eg: goki)
+	Org string `def:"goki"`
+
+	// Protocol is the protocol used to clone repositories from
+	// the Git host: "https" or "ssh"
+	Protocol string `def:"https"`
+
+	// Source is the source of the repositories that clone operates on:
+	// "website" (a repositories page like https://goki.dev/repositories),
+	// "manifest" (the repositories listed in the workspace manifest),
+	// "work" (the modules used in a go.work file), or "github"
+	// (a GitHub organization JSON repository listing).
+	Source string `def:"website"`
+
+	// SourceURL is the URL (or file path for the "work" source)
+	// of the repository source. If it is unset, the default
+	// location for the source is used.
+	SourceURL string
+
+	// Refresh is whether to require fetching the repository list from
+	// the website, instead of falling back on the cached list when the
+	// website can not be reached.
+	Refresh bool
+
+	// Jobs is the maximum number of repositories to process
+	// concurrently. If it is 0, the number of CPUs is used.
+	Jobs int
+
+	// Timeout is the maximum duration of each external command run by
+	// gsm (eg: 10m or 30s), after which the command is stopped. If it
+	// is 0, there is no limit.
+	Timeout string `def:"10m"`
+
+	// Retries is the maximum number of times to retry network Git
+	// operations (like cloning and pulling) that fail because of
+	// transient problems like connection resets and server errors.
+	Retries int `def:"3"`
+
+	// RetryDelay is the delay before the first retry of a network Git
+	// operation (eg: 1s or 500ms), which doubles after each retry.
+	RetryDelay string `def:"1s"`
+
+	// Format is the output format of commands that report on
+	// repositories: text (human-readable tables), json (a single
+	// JSON object), or ndjson (one JSON object per line for each
+	// repository, which can be processed incrementally).
+	Format string `cmd:"changed,pull,clone,release,list,status,changelog,apply,commit,push" def:"text"`
+
+	// Output is how the output of the external commands run concurrently on
+	// each repository is printed, with each line prefixed with the name of the
+	// repository: block (all together once the repository is done, so that
+	// the output of different repositories does not interleave) or stream
+	// (as soon as each command finishes).
+	Output string `cmd:"changed,pull,clone,exec,commit,push" def:"block"`
+
+	// LogDir is the directory in which to write a log file for each
+	// repository containing all of the external commands run on it and
+	// their output, for investigating failures. Each run writes its logs
+	// to a new subdirectory named by the time of the run. If it is "",
+	// no log files are written.
+	LogDir string `cmd:"changed,pull,clone,exec,commit,push"`
+
+	// Repos are the names or glob patterns (in the format of [path.Match])
+	// of the repositories to run commands on. If it is empty, commands
+	// run on all repositories.
+	Repos []string
+
+	// Exclude are the names or glob patterns (in the format of
+	// [path.Match]) of repositories to not run commands on.
+	Exclude []string
+
+	// Group are the groups specified in the workspace manifest of
+	// the repositories to run commands on. If it is empty, commands
+	// run on repositories in all groups.
+	Group []string
+
+	// ChangedOnly is whether to only run commands on repositories that
+	// have changes that need to be updated in version control.
+	ChangedOnly bool
+
+	// Closure is the name of a repository to restrict commands to the
+	// dependency closure of, which consists of the repository and all
+	// of the repositories it directly or indirectly depends on.
+	Closure string
+
 	// Update is whether to update dependencies and tidy modules
 	// when doing a release cycle. It should only be turned off
 	// in rare cases in which updating dependencies or tidying
-	// modules would cause problems or is not possible.
-	Update bool `cmd:"release" def:"true"`
+	// modules would cause problems or is not possible. It also
+	// determines whether changelogs include Goki import updates.
+	Update bool `cmd:"release,changelog" def:"true"`
+
+	// DryRun is whether to only print the plan for a release cycle
+	// (the changed repositories, the release order, the Goki imports
+	// that will be updated, and the predicted versions) without
+	// changing any repositories.
+	DryRun bool `cmd:"release"`
+
+	// Bump overrides the inferred version bump (patch, minor, or major)
+	// for specific repositories when releasing (eg: gi=minor,gti=patch).
+	// The bump for other repositories is inferred from the conventional
+	// commit messages and exported API changes since their last release.
+	Bump map[string]string `cmd:"release,changelog"`
 
 	// The name of the repository to create a vanity import site for.
 	// A major version suffix can be added to the end of the repository name
 	// (eg: "gi/v2")
 	Repository string `cmd:"new-vanity" posarg:"0"`
 
+	// The module to print the dependents of, specified as a module
+	// path (eg: goki.dev/laser) or a repository name (eg: laser)
+	Module string `cmd:"dependents" posarg:"0"`
+
 	// the config info for the make-ios-framework command
 	IOSFramework IOSFramework `cmd:"make-ios-framework"`
+
+	// the config info for the graph command
+	Graph GraphConfig `cmd:"graph"`
+
+	// the config info for the exec command
+	Exec ExecConfig `cmd:"exec"`
+
+	// the config info for the diff command
+	Diff DiffConfig `cmd:"diff"`
+
+	// the config info for the apply command
+	Apply ApplyConfig `cmd:"apply"`
+
+	// the config info for the commit command
+	Commit CommitConfig `cmd:"commit"`
+
+	// git is the Git implementation to use, or nil to use [ExecGit];
+	// it is not associated with any commands so that it is not a flag
+	git Git `cmd:"-"`
+}
+
+// SetGit sets the [Git] implementation that commands run with the config
+// use (see [GitFrom]), which is typically only needed for testing.
+func (c *Config) SetGit(g Git) *Config {
+	c.git = g
+	return c
+}
+
+// IsVanityPath returns whether the given module path
+// starts with the configured vanity import path prefix.
+func (c *Config) IsVanityPath(modPath string) bool {
+	return modPath == c.Vanity || strings.HasPrefix(modPath, c.Vanity+"/")
+}
+
+// RepositoryURL returns the URL of the repository with the
+// given name on the configured Git host (including https://).
+func (c *Config) RepositoryURL(name string) string {
+	return "https://" + path.Join(c.Host, c.Org, name)
+}
+
+// CloneURL returns the URL that should be used to clone the given repository
+// using the configured protocol. If the protocol is ssh and the URL of the
+// repository is on the configured Git host, it returns the equivalent ssh URL;
+// otherwise, it returns the URL of the repository.
+func (c *Config) CloneURL(rep *Repository) string {
+	prefix := "https://" + c.Host + "/"
+	if c.Protocol != "ssh" || !strings.HasPrefix(rep.RepositoryURL, prefix) {
+		return rep.RepositoryURL
+	}
+	return "git@" + c.Host + ":" + strings.TrimPrefix(rep.RepositoryURL, prefix) + ".git"
+}
+
+// NewRepository returns a new [Repository] with the given name,
+// with its title and URLs based on the configuration information.
+func (c *Config) NewRepository(name string) *Repository {
+	return &Repository{
+		Name:          name,
+		Title:         strcase.ToCamel(name),
+		RepositoryURL: c.RepositoryURL(name),
+		VanityURL:     path.Join(c.Vanity, name),
+	}
+}
+
+// LocalRepository returns a new [Repository] for the Git repository in
+// the given slash-separated directory on the local filesystem, relative to
+// the current directory, with its name based on the name of the directory.
+func (c *Config) LocalRepository(dir string) *Repository {
+	// can't use mod path because of major version suffixes; easier to just use this
+	nm := path.Base(dir)
+	if dir == "." {
+		wd, _ := os.Getwd()
+		nm = filepath.Base(wd)
+	}
+	rep := c.NewRepository(nm)
+	rep.Dir = dir
+	return rep
 }
 
 type IOSFramework struct { //gti:add
@@ -37,3 +238,54 @@ type IOSFramework struct { //gti:add
 	// the organization to use in the bundle id for the resulting framework
 	Organization string
 }
+
+type GraphConfig struct { //gti:add
+
+	// the format to print the graph in: dot, mermaid, or json
+	Format string `def:"dot"`
+
+	// whether to highlight the repositories that have changed since their last release
+	Changed bool
+
+	// whether to label dependencies with the versions required in go.mod files
+	Versions bool
+
+	// the name of a repository to restrict the graph to the dependency closure of
+	Root string `posarg:"0" required:"-"`
+}
+
+type ExecConfig struct { //gti:add
+
+	// the command to run in each repository and its arguments,
+	// which can contain templates like {{.Name}} (eg: gsm exec -- git log -1)
+	Command []string `posarg:"all"`
+
+	// whether to stop starting the command in more repositories
+	// once it fails in one of them, instead of continuing
+	FailFast bool
+}
+
+type DiffConfig struct { //gti:add
+
+	// the file to write the patch bundle to instead of printing it
+	File string
+}
+
+type ApplyConfig struct { //gti:add
+
+	// the patch bundle file written by the diff command
+	// to apply, or - to read it from standard input
+	File string `posarg:"0"`
+}
+
+type CommitConfig struct { //gti:add
+
+	// the commit message
+	Message string `flag:"m,message"`
+
+	// whether to also commit untracked files
+	All bool `flag:"a,all"`
+
+	// whether to ask whether to commit in each changed repository
+	Interactive bool `flag:"i,interactive"`
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/mod/semver"
+)
+
+// Dependents prints all of the Goki Git repositories in the current directory
+// that directly or indirectly depend on the config module, which can be specified
+// as a module path or a repository name. For each dependent, it prints the versions
+// of the module required in its go.mod files and whether they are behind the
+// latest version tag of the module. Only the dependents selected by the selector
+// flags are printed.
+func Dependents(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	g, err := LocalDependencyGraph(ctx, c)
+	if err != nil {
+		return err
+	}
+	target := g.Repository(c.Module)
+	if target == nil {
+		target = g.Repository(path.Join(c.Vanity, c.Module))
+	}
+	if target == nil {
+		target = g.RepositoryByName(c.Module)
+	}
+	if target == nil {
+		return fmt.Errorf("module %q not found", c.Module)
+	}
+	latest, err := GitFrom(ctx).Describe(ctx, target.Dir)
+	if errors.Is(err, ErrNoTags) {
+		// it has never been released, so we can not tell whether dependents are behind
+		latest = ""
+	} else if err != nil {
+		return fmt.Errorf("error getting latest version of repository %q: %w", target.Name, err)
+	}
+	direct := g.DirectDependents(target)
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	sel, err := NewSelection(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	deps, err := SelectRepositories(ctx, sel, g.Dependents(target))
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DEPENDENT\tKIND\tREQUIRES\tLATEST\tBEHIND")
+	for _, dep := range deps {
+		kind := "indirect"
+		if slices.Contains(direct, dep) {
+			kind = "direct"
+		}
+		vers := dep.RequiredVersions(target)
+		behind := "no"
+		if latest == "" || len(vers) == 0 {
+			behind = "?"
+		} else if semver.Compare(vers[0], latest) < 0 { // vers is sorted, so the first one is the oldest
+			behind = "yes"
+		}
+		req := strings.Join(vers, ", ")
+		if req == "" {
+			req = "-"
+		}
+		lat := latest
+		if lat == "" {
+			lat = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", dep.Name, kind, req, lat, behind)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// describeErrorGit is a [Git] whose Describe always fails with Err.
+type describeErrorGit struct {
+	Git
+	Err error
+}
+
+func (dg *describeErrorGit) Describe(ctx context.Context, dir string) (string, error) {
+	return "", dg.Err
+}
+
+func TestDependentsFakeGit(t *testing.T) {
+	w := newFakeWorkspace(t)
+	base := w.AddRepository("base", map[string]string{"go.mod": "module goki.dev/base\n\ngo 1.21\n"})
+	w.AddRepository("mid", map[string]string{"go.mod": "module goki.dev/mid\n\ngo 1.21\n\nrequire goki.dev/base v0.1.0\n"})
+	w.AddRepository("top", map[string]string{"go.mod": "module goki.dev/top\n\ngo 1.21\n\nrequire goki.dev/mid v0.1.0\n"})
+	base.AddCommit("feat: add Hello", map[string]string{"base.go": "package base\n"})
+	base.Tags["v0.2.0"] = 1
+
+	dependents := func(c *Config) []string {
+		t.Helper()
+		c.Module = "base"
+		out, err := captureStdout(t, func() error { return Dependents(c) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := []string{}
+		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n")[1:] {
+			lines = append(lines, strings.Join(strings.Fields(line), " "))
+		}
+		return lines
+	}
+	want := "mid direct v0.1.0 v0.2.0 yes,top indirect - v0.2.0 ?"
+	if got := strings.Join(dependents(w.Config()), ","); got != want {
+		t.Errorf("expected dependents %q, but got %q", want, got)
+	}
+
+	// if base has never been released, we can not tell whether it is behind
+	base.Tags = nil
+	want = "mid direct v0.1.0 - ?,top indirect - - ?"
+	if got := strings.Join(dependents(w.Config()), ","); got != want {
+		t.Errorf("expected dependents %q, but got %q", want, got)
+	}
+
+	// other errors getting the latest version must not be ignored
+	errDescribe := errors.New("git describe timed out")
+	c := w.Config().SetGit(&describeErrorGit{Git: w.Git, Err: errDescribe})
+	c.Module = "base"
+	_, err := captureStdout(t, func() error { return Dependents(c) })
+	if !errors.Is(err, errDescribe) {
+		t.Errorf("expected the describe error, but got %v", err)
+	}
+}
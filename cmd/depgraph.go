@@ -0,0 +1,188 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// DependencyGraph is a dependency graph of repositories, in which each repository
+// depends on the repositories containing its Goki imports.
+type DependencyGraph struct {
+
+	// Repositories are the repositories in the graph
+	Repositories []*Repository
+
+	// Dependencies are the repositories that each repository depends on
+	Dependencies map[*Repository][]*Repository
+
+	// modules maps module paths to the repositories containing them
+	modules map[string]*Repository
+}
+
+// NewDependencyGraph returns a new [DependencyGraph] for the given repositories.
+// If any of the Goki imports of the repositories are not contained in any of the
+// given repositories, it still returns the graph without those dependencies,
+// but it also returns an error listing all of the missing imports.
+func NewDependencyGraph(reps []*Repository) (*DependencyGraph, error) {
+	g := &DependencyGraph{
+		Repositories: reps,
+		Dependencies: map[*Repository][]*Repository{},
+		modules:      map[string]*Repository{},
+	}
+	for _, rep := range reps {
+		for _, mod := range rep.Modules {
+			g.modules[mod.Path] = rep
+		}
+	}
+	errs := []error{}
+	for _, rep := range reps {
+		for _, imp := range rep.GokiImports {
+			dep := g.modules[imp]
+			if dep == nil {
+				errs = append(errs, fmt.Errorf("missing repository for import %q of repository %q; you might need to run gsm clone", imp, rep.Name))
+				continue
+			}
+			if dep != rep && !slices.Contains(g.Dependencies[rep], dep) {
+				g.Dependencies[rep] = append(g.Dependencies[rep], dep)
+			}
+		}
+	}
+	return g, errors.Join(errs...)
+}
+
+// Repository returns the repository in the graph containing
+// the module with the given module path, or nil if there is none.
+func (g *DependencyGraph) Repository(modPath string) *Repository {
+	return g.modules[modPath]
+}
+
+// RepositoryByName returns the repository in the graph
+// with the given name, or nil if there is none.
+func (g *DependencyGraph) RepositoryByName(name string) *Repository {
+	for _, rep := range g.Repositories {
+		if rep.Name == name {
+			return rep
+		}
+	}
+	return nil
+}
+
+// Closure returns the given repository and all of the repositories
+// that it directly or indirectly depends on, in the order of
+// [DependencyGraph.Repositories].
+func (g *DependencyGraph) Closure(rep *Repository) []*Repository {
+	seen := map[*Repository]bool{}
+	var visit func(rep *Repository)
+	visit = func(rep *Repository) {
+		if seen[rep] {
+			return
+		}
+		seen[rep] = true
+		for _, dep := range g.Dependencies[rep] {
+			visit(dep)
+		}
+	}
+	visit(rep)
+	res := []*Repository{}
+	for _, rep := range g.Repositories {
+		if seen[rep] {
+			res = append(res, rep)
+		}
+	}
+	return res
+}
+
+// DirectDependents returns all of the repositories in the
+// graph that directly depend on the given repository, in the
+// order of [DependencyGraph.Repositories].
+func (g *DependencyGraph) DirectDependents(rep *Repository) []*Repository {
+	res := []*Repository{}
+	for _, r := range g.Repositories {
+		if slices.Contains(g.Dependencies[r], rep) {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// Dependents returns all of the repositories in the graph that directly
+// or indirectly depend on the given repository, with direct dependents
+// first, followed by the dependents of those, and so on.
+func (g *DependencyGraph) Dependents(rep *Repository) []*Repository {
+	res := []*Repository{}
+	queue := []*Repository{rep}
+	for len(queue) > 0 {
+		for _, dep := range g.DirectDependents(queue[0]) {
+			if dep != rep && !slices.Contains(res, dep) {
+				res = append(res, dep)
+				queue = append(queue, dep)
+			}
+		}
+		queue = queue[1:]
+	}
+	return res
+}
+
+// Components returns the strongly connected components of the graph
+// in topological order, such that each component only depends on itself
+// and components that come before it. Each component with more than one
+// repository is a cycle of repositories that (indirectly) import each other.
+func (g *DependencyGraph) Components() [][]*Repository {
+	// this is Tarjan's strongly connected components algorithm, which
+	// naturally finds the components in reverse topological order of the
+	// dependency edges, which is the order we want since our edges go from
+	// dependents to dependencies.
+	index := map[*Repository]int{}
+	lowlink := map[*Repository]int{}
+	onStack := map[*Repository]bool{}
+	stack := []*Repository{}
+	res := [][]*Repository{}
+
+	var connect func(rep *Repository)
+	connect = func(rep *Repository) {
+		index[rep] = len(index)
+		lowlink[rep] = index[rep]
+		stack = append(stack, rep)
+		onStack[rep] = true
+
+		for _, dep := range g.Dependencies[rep] {
+			if _, visited := index[dep]; !visited {
+				connect(dep)
+				lowlink[rep] = min(lowlink[rep], lowlink[dep])
+			} else if onStack[dep] {
+				lowlink[rep] = min(lowlink[rep], index[dep])
+			}
+		}
+
+		if lowlink[rep] != index[rep] {
+			return
+		}
+		comp := []*Repository{}
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			comp = append(comp, top)
+			if top == rep {
+				break
+			}
+		}
+		// we keep the repositories in each component in a stable order
+		slices.SortFunc(comp, func(a, b *Repository) int {
+			return slices.Index(g.Repositories, a) - slices.Index(g.Repositories, b)
+		})
+		res = append(res, comp)
+	}
+
+	for _, rep := range g.Repositories {
+		if _, visited := index[rep]; !visited {
+			connect(rep)
+		}
+	}
+	return res
+}
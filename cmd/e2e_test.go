@@ -0,0 +1,495 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+)
+
+// testRepositories are the repositories of the workspaces of the end-to-end
+// tests: base has no dependencies, mid depends on base, and top depends on both.
+var testRepositories = []testRepository{
+	{Name: "base"},
+	{Name: "mid", Deps: []string{"base"}},
+	{Name: "top", Deps: []string{"base", "mid"}},
+}
+
+// newClonedWorkspace returns a new [testWorkspace] with [testRepositories]
+// that have been cloned with [Clone].
+func newClonedWorkspace(t *testing.T) *testWorkspace {
+	w := newTestWorkspace(t, testRepositories...)
+	rs, err := runGsm(t, Clone, w.Config())
+	if err != nil {
+		t.Fatalf("error cloning: %v", err)
+	}
+	for name, status := range statuses(rs) {
+		if status != StatusChanged {
+			t.Fatalf("expected %s to be cloned (changed), but got %s", name, status)
+		}
+	}
+	return w
+}
+
+// checkStatuses checks that the given results have the given statuses.
+func checkStatuses(t *testing.T, rs *ResultsJSON, want map[string]Status) {
+	t.Helper()
+	got := statuses(rs)
+	if len(got) != len(want) {
+		t.Errorf("expected results for %d repositories, but got %d: %v", len(want), len(got), got)
+	}
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("expected status of %s to be %s, but got %s", name, status, got[name])
+		}
+	}
+}
+
+// checkChanges checks that the repositories of the given results have the
+// given categories of local changes, separated by spaces.
+func checkChanges(t *testing.T, rs *ResultsJSON, want map[string]string) {
+	t.Helper()
+	for _, r := range rs.Results {
+		chs := []string{}
+		for _, ch := range r.Repository.Changes {
+			chs = append(chs, string(ch))
+		}
+		if got := strings.Join(chs, " "); got != want[r.Repository.Name] {
+			t.Errorf("expected changes of %s to be %q, but got %q", r.Repository.Name, want[r.Repository.Name], got)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	w := newClonedWorkspace(t)
+	for _, rep := range testRepositories {
+		if _, err := os.Stat(filepath.Join(w.Dir, rep.Name, "go.mod")); err != nil {
+			t.Errorf("expected %s to be cloned: %v", rep.Name, err)
+		}
+	}
+	rs, err := runGsm(t, Clone, w.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusSkipped, "mid": StatusSkipped, "top": StatusSkipped})
+}
+
+func TestPull(t *testing.T) {
+	w := newClonedWorkspace(t)
+	w.CommitRemote("base", "docs: add readme", map[string]string{"README.md": "# base\n"})
+	rs, err := runGsm(t, Pull, w.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusChanged, "mid": StatusOK, "top": StatusOK})
+	if _, err := os.Stat(filepath.Join(w.Dir, "base", "README.md")); err != nil {
+		t.Errorf("expected pulled file to exist: %v", err)
+	}
+}
+
+func TestChanged(t *testing.T) {
+	w := newClonedWorkspace(t)
+	writeFiles(t, w.Dir, map[string]string{"mid/mid.go": "package mid\n"})
+	writeFiles(t, w.Dir, map[string]string{"top/doc.go": "// Package top is a test package.\npackage top\n"})
+	w.git(filepath.Join(w.Dir, "top"), "add", "doc.go")
+	w.git(filepath.Join(w.Dir, "top"), "commit", "-q", "-m", "docs: add package doc")
+	rs, err := runGsm(t, Changed, w.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusOK, "mid": StatusChanged, "top": StatusChanged})
+
+	c := w.Config()
+	c.ChangedOnly = true
+	c.Exclude = []string{"top"}
+	rs, err = runGsm(t, Changed, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatuses(t, rs, map[string]Status{"mid": StatusChanged})
+}
+
+func TestWork(t *testing.T) {
+	w := newClonedWorkspace(t)
+	c := w.Config()
+	c.Exclude = []string{"top"}
+	_, err := captureStdout(t, func() error { return Work(c) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	mods := w.run(w.Dir, "go", "list", "-m")
+	if mods != "example.test/base\nexample.test/mid" {
+		t.Errorf("expected go.work to use base and mid, but got modules:\n%s", mods)
+	}
+}
+
+func TestRelease(t *testing.T) {
+	w := newClonedWorkspace(t)
+	w.CommitRemote("base", "feat: add Hello", map[string]string{"hello.go": "package base\n\n// Hello returns a greeting.\nfunc Hello() string { return \"hello\" }\n"})
+	if _, err := runGsm(t, Pull, w.Config()); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"base": "v0.2.0", "mid": "v0.1.1", "top": "v0.1.1"}
+
+	c := w.Config()
+	c.DryRun = true
+	out, err := captureStdout(t, func() error { return Release(c) })
+	if err != nil {
+		t.Fatalf("error planning release: %v\n%s", err, out)
+	}
+	p := &ReleasePlan{}
+	if err := json.Unmarshal(out, p); err != nil {
+		t.Fatalf("error parsing release plan: %v\n%s", err, out)
+	}
+	order := []string{}
+	for _, step := range p.Steps {
+		order = append(order, step.Repository.Name)
+		if step.NextVersion != want[step.Repository.Name] {
+			t.Errorf("expected planned version of %s to be %s, but got %s", step.Repository.Name, want[step.Repository.Name], step.NextVersion)
+		}
+	}
+	if strings.Join(order, " ") != "base mid top" {
+		t.Errorf("expected release order base mid top, but got %v", order)
+	}
+
+	rs, err := runGsm(t, Release, w.Config())
+	if err != nil {
+		t.Fatalf("error releasing: %v\n%+v", err, rs)
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusChanged, "mid": StatusChanged, "top": StatusChanged})
+	for name, version := range want {
+		if got := w.git(w.Remote(name), "describe", "--abbrev=0", "main"); got != version {
+			t.Errorf("expected latest release of %s to be %s, but got %s", name, version, got)
+		}
+		if _, err := os.Stat(filepath.Join(w.Proxy, "example.test", name, "@v", version+".zip")); err != nil {
+			t.Errorf("expected %s %s to be published: %v", name, version, err)
+		}
+	}
+	mod := w.git(w.Remote("top"), "show", "v0.1.1:go.mod")
+	for _, req := range []string{"example.test/base v0.2.0", "example.test/mid v0.1.1"} {
+		if !strings.Contains(mod, req) {
+			t.Errorf("expected go.mod of top v0.1.1 to require %s, but got:\n%s", req, mod)
+		}
+	}
+
+	// nothing has changed since the release, so there should be nothing to release
+	rs, err = runGsm(t, Release, w.Config())
+	if err != nil {
+		t.Fatalf("error releasing again: %v", err)
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusOK, "mid": StatusOK, "top": StatusOK})
+}
+
+// TestReleaseCycle tests releasing two repositories whose modules
+// import each other, which must be released together and then pinned
+// to each other's new versions.
+func TestReleaseCycle(t *testing.T) {
+	w := newTestWorkspace(t, testRepository{Name: "cyca"}, testRepository{Name: "cycb"})
+	// cyca and cycb import each other through different packages,
+	// so their modules are in an import cycle
+	w.CommitRemote("cyca", "feat: use cycb", map[string]string{
+		"cyca.go": "package cyca\n\nimport \"example.test/cycb\"\n\n// Name returns the name of the package.\nfunc Name() string { return \"cyca\" }\n\n// Both returns the names of both packages.\nfunc Both() string { return Name() + \" \" + cycb.Name() }\n",
+		"go.mod":  "module example.test/cyca\n\ngo 1.21\n\nrequire example.test/cycb v0.1.0\n",
+	})
+	w.CommitRemote("cycb", "feat: add util", map[string]string{
+		"util/util.go": "package util\n\nimport \"example.test/cyca\"\n\n// Name returns the name of cyca.\nfunc Name() string { return cyca.Name() }\n",
+		"go.mod":       "module example.test/cycb\n\ngo 1.21\n\nrequire example.test/cyca v0.1.0\n",
+	})
+	if _, err := runGsm(t, Clone, w.Config()); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := runGsm(t, Release, w.Config())
+	if err != nil {
+		t.Fatalf("error releasing: %v", err)
+	}
+	checkStatuses(t, rs, map[string]Status{"cyca": StatusChanged, "cycb": StatusChanged})
+
+	tags := map[string][]string{}
+	for _, name := range []string{"cyca", "cycb"} {
+		tags[name] = strings.Fields(w.git(w.Remote(name), "tag", "--list"))
+		if latest := tags[name][len(tags[name])-1]; latest == "v0.1.0" {
+			t.Fatalf("expected %s to be released, but its latest tag is %s", name, latest)
+		}
+	}
+	// the latest release of each repository must require
+	// a new released version of the other one
+	for name, other := range map[string]string{"cyca": "cycb", "cycb": "cyca"} {
+		latest := tags[name][len(tags[name])-1]
+		gomod := w.git(w.Remote(name), "show", latest+":go.mod")
+		mf, err := modfile.ParseLax("go.mod", []byte(gomod), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		required := ""
+		for _, req := range mf.Require {
+			if req.Mod.Path == "example.test/"+other {
+				required = req.Mod.Version
+			}
+		}
+		if required == "v0.1.0" || !slices.Contains(tags[other], required) {
+			t.Errorf("expected %s %s to require a new release of %s (one of %v), but it requires %q", name, latest, other, tags[other], required)
+		}
+	}
+}
+
+func TestStatus(t *testing.T) {
+	w := newClonedWorkspace(t)
+	writeFiles(t, w.Dir, map[string]string{"top/doc.go": "// Package top is a test package.\npackage top\n"})
+	w.git(filepath.Join(w.Dir, "top"), "add", "doc.go")
+	w.git(filepath.Join(w.Dir, "top"), "commit", "-q", "-m", "docs: add package doc")
+	writeFiles(t, w.Dir, map[string]string{"mid/extra.go": "package mid\n"})
+	writeFiles(t, w.Dir, map[string]string{"base/go.mod": "module example.test/base\n\ngo 1.21\n\n// changed\n"})
+	w.git(filepath.Join(w.Dir, "base"), "stash", "-q")
+
+	c := w.Config()
+	c.Format = "json"
+	out, err := captureStdout(t, func() error { return PrintStatus(c) })
+	if err != nil {
+		t.Fatalf("error getting status: %v\n%s", err, out)
+	}
+	sts := []*RepositoryStatus{}
+	if err := json.Unmarshal(out, &sts); err != nil {
+		t.Fatalf("error parsing status: %v\n%s", err, out)
+	}
+	got := map[string]*RepositoryStatus{}
+	for _, st := range sts {
+		got[st.Repository.Name] = st
+	}
+	for _, name := range []string{"base", "mid", "top"} {
+		st := got[name]
+		if st == nil || st.Git == nil {
+			t.Fatalf("expected status of %s, but got %+v", name, st)
+		}
+		if st.Git.Branch != "main" || st.Git.Upstream != "origin/main" || st.Tag != "v0.1.0" {
+			t.Errorf("expected %s to be on main tracking origin/main at v0.1.0, but got %+v (tag %q)", name, st.Git, st.Tag)
+		}
+	}
+	if st := got["base"]; st.Status != StatusOK || st.Git.Stash != 1 {
+		t.Errorf("expected base to be clean with one stash entry, but got %s and %+v", st.Status, st.Git)
+	}
+	if st := got["mid"]; st.Status != StatusChanged || len(st.Git.Untracked) != 1 || st.Git.Untracked[0] != "extra.go" {
+		t.Errorf("expected mid to have untracked extra.go, but got %s and %+v", st.Status, st.Git)
+	}
+	if st := got["top"]; st.Status != StatusChanged || st.Git.Ahead != 1 || st.SinceTag != 1 {
+		t.Errorf("expected top to be one commit ahead and since its tag, but got %s and %+v (%d since tag)", st.Status, st.Git, st.SinceTag)
+	}
+}
+
+func TestChangedCategories(t *testing.T) {
+	w := newClonedWorkspace(t)
+	writeFiles(t, w.Dir, map[string]string{"base/doc.go": "// Package base is a test package.\npackage base\n"})
+	w.git(filepath.Join(w.Dir, "base"), "add", "doc.go")
+	writeFiles(t, w.Dir, map[string]string{"mid/extra.go": "package mid\n"})
+	w.git(filepath.Join(w.Dir, "mid"), "checkout", "-q", "-b", "feature")
+	writeFiles(t, w.Dir, map[string]string{"top/doc.go": "// Package top is a test package.\npackage top\n"})
+	w.git(filepath.Join(w.Dir, "top"), "add", "doc.go")
+	w.git(filepath.Join(w.Dir, "top"), "commit", "-q", "-m", "docs: add package doc")
+	w.CommitRemote("top", "docs: add readme", map[string]string{"README.md": "# top\n"})
+	w.git(filepath.Join(w.Dir, "top"), "fetch", "-q")
+
+	rs, err := runGsm(t, Changed, w.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusChanged, "mid": StatusChanged, "top": StatusChanged})
+	checkChanges(t, rs, map[string]string{"base": "staged", "mid": "untracked no-upstream", "top": "diverged"})
+}
+
+func TestChangelog(t *testing.T) {
+	w := newClonedWorkspace(t)
+	w.CommitRemote("base", "feat: add Hello", map[string]string{"hello.go": "package base\n\n// Hello returns a greeting.\nfunc Hello() string { return \"hello\" }\n"})
+	w.CommitRemote("base", "fix(hello): punctuate greeting", map[string]string{"hello.go": "package base\n\n// Hello returns a greeting.\nfunc Hello() string { return \"hello!\" }\n"})
+	w.CommitRemote("top", "update readme", map[string]string{"README.md": "# top\n"})
+	if _, err := runGsm(t, Pull, w.Config()); err != nil {
+		t.Fatal(err)
+	}
+
+	c := w.Config()
+	c.Format = "json"
+	out, err := captureStdout(t, func() error { return Changelog(c) })
+	if err != nil {
+		t.Fatalf("error getting changelog: %v\n%s", err, out)
+	}
+	cls := []*RepositoryChangelog{}
+	if err := json.Unmarshal(out, &cls); err != nil {
+		t.Fatalf("error parsing changelog: %v\n%s", err, out)
+	}
+	if len(cls) != 2 || cls[0].Repository.Name != "base" || cls[1].Repository.Name != "top" {
+		t.Fatalf("expected changelogs for base and top, but got:\n%s", out)
+	}
+	base, top := cls[0], cls[1]
+	if base.Version != "v0.1.0" || base.NextVersion != "v0.2.0" {
+		t.Errorf("expected base to go from v0.1.0 to v0.2.0, but got %s to %s", base.Version, base.NextVersion)
+	}
+	types := []string{}
+	for _, grp := range base.Groups {
+		types = append(types, grp.Type)
+		if len(grp.Commits) != 1 || strings.Join(grp.Commits[0].Files, " ") != "hello.go" {
+			t.Errorf("expected one commit touching hello.go in the %s group of base, but got %+v", grp.Type, grp.Commits)
+		}
+	}
+	if strings.Join(types, " ") != "feat fix" {
+		t.Errorf("expected base groups feat and fix, but got %v", types)
+	}
+	if len(top.Groups) != 1 || top.Groups[0].Type != "other" || top.Groups[0].Commits[0].Message != "update readme" {
+		t.Errorf("expected top to have an other group with the readme commit, but got %+v", top.Groups)
+	}
+	if bumps := strings.Join(top.Bumps, " "); bumps != "example.test/base@v0.2.0 example.test/mid@v0.1.1" {
+		t.Errorf("expected top to update base to v0.2.0 and mid to v0.1.1, but got %s", bumps)
+	}
+}
+
+func TestDiffApply(t *testing.T) {
+	w := newClonedWorkspace(t)
+	changes := map[string]string{
+		"base/base.go":     "package base\n\n// Base is changed.\nconst Base = 2\n",
+		"base/extra.go":    "package base\n",
+		"top/sub/notes.md": "# notes\n",
+	}
+	writeFiles(t, w.Dir, changes)
+
+	c := w.Config()
+	c.Diff.File = filepath.Join(t.TempDir(), "bundle.patch")
+	if _, err := captureStdout(t, func() error { return Diff(c) }); err != nil {
+		t.Fatalf("error exporting patch bundle: %v", err)
+	}
+	b, err := os.ReadFile(c.Diff.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# repository: base ", "# repository: top ", "diff --git a/base/base.go b/base/base.go", "b/top/sub/notes.md"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("expected patch bundle to contain %q, but got:\n%s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "# repository: mid") {
+		t.Errorf("expected patch bundle to not contain unchanged mid, but got:\n%s", b)
+	}
+
+	for _, name := range []string{"base", "top"} {
+		w.git(filepath.Join(w.Dir, name), "checkout", "-q", ".")
+		w.git(filepath.Join(w.Dir, name), "clean", "-q", "-fd")
+	}
+	file := c.Diff.File
+	c = w.Config()
+	c.Apply.File = file
+	rs, err := runGsm(t, Apply, c)
+	if err != nil {
+		t.Fatalf("error applying patch bundle: %v", err)
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusChanged, "top": StatusChanged})
+	for fpath, want := range changes {
+		got, err := os.ReadFile(filepath.Join(w.Dir, filepath.FromSlash(fpath)))
+		if err != nil || string(got) != want {
+			t.Errorf("expected %s to be %q after applying, but got %q (%v)", fpath, want, got, err)
+		}
+	}
+
+	// the patches no longer apply, so nothing should change
+	if _, err := captureStdout(t, func() error { return Apply(c) }); err == nil {
+		t.Errorf("expected applying the patch bundle again to fail")
+	}
+}
+
+func TestCommitPush(t *testing.T) {
+	w := newClonedWorkspace(t)
+	writeFiles(t, w.Dir, map[string]string{
+		"base/base.go":  "package base\n\n// Base is changed.\nconst Base = 2\n",
+		"mid/extra.go":  "package mid\n",
+		"top/README.md": "# top\n",
+	})
+	w.git(filepath.Join(w.Dir, "top"), "add", "README.md")
+	w.git(filepath.Join(w.Dir, "top"), "commit", "-q", "-m", "docs: add readme")
+	w.CommitRemote("top", "docs: add license", map[string]string{"LICENSE": "BSD\n"})
+	w.git(filepath.Join(w.Dir, "top"), "fetch", "-q")
+
+	c := w.Config()
+	c.Commit.Message = "fix: update everything"
+	rs, err := runGsm(t, Commit, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// mid only has an untracked file, which needs the all flag
+	checkStatuses(t, rs, map[string]Status{"base": StatusChanged, "mid": StatusSkipped, "top": StatusOK})
+	checkChanges(t, rs, map[string]string{"base": "unstaged", "mid": "untracked", "top": "diverged"})
+	c.Commit.All = true
+	rs, err = runGsm(t, Commit, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusOK, "mid": StatusChanged, "top": StatusOK})
+
+	rs, err = runGsm(t, Push, w.Config())
+	if err == nil {
+		t.Errorf("expected pushing diverged top to fail")
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusChanged, "mid": StatusChanged, "top": StatusFailed})
+	for _, name := range []string{"base", "mid"} {
+		if got := w.git(w.Remote(name), "log", "-1", "--format=%s", "main"); got != "fix: update everything" {
+			t.Errorf("expected latest commit of %s remote to be the new commit, but got %q", name, got)
+		}
+	}
+	w.git(filepath.Join(w.Dir, "base"), "checkout", "-q", "-b", "feature")
+	rs, err = runGsm(t, Push, w.Config())
+	if err == nil {
+		t.Errorf("expected pushing diverged top to fail again")
+	}
+	// base has no upstream for its new branch, which is not an error
+	checkStatuses(t, rs, map[string]Status{"base": StatusSkipped, "mid": StatusOK, "top": StatusFailed})
+	checkChanges(t, rs, map[string]string{"base": "no-upstream", "mid": "", "top": "diverged"})
+}
+
+func TestPullRetry(t *testing.T) {
+	w := newClonedWorkspace(t)
+	w.CommitRemote("base", "docs: add readme", map[string]string{"README.md": "# base\n"})
+	// the upload pack of the remote of base fails like a dropped
+	// connection the given number of times before working
+	count := filepath.Join(t.TempDir(), "count")
+	script := filepath.Join(t.TempDir(), "flaky-upload-pack")
+	writeFiles(t, filepath.Dir(script), map[string]string{filepath.Base(script): fmt.Sprintf(`#!/bin/sh
+echo x >> '%s'
+if [ "$(wc -l < '%s')" -le "$GSM_TEST_FAILURES" ]; then
+	echo "fatal: the remote end hung up unexpectedly" >&2
+	exit 128
+fi
+exec git-upload-pack "$@"
+`, count, count)})
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	w.git(filepath.Join(w.Dir, "base"), "config", "remote.origin.uploadpack", script)
+
+	t.Setenv("GSM_TEST_FAILURES", "5")
+	c := w.Config()
+	c.Repos = []string{"base"}
+	c.Retries, c.RetryDelay = 1, "10ms"
+	rs, err := runGsm(t, Pull, c)
+	if err == nil {
+		t.Errorf("expected pull to fail after running out of retries")
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusFailed})
+	if n := strings.Count(w.run(w.Dir, "cat", count), "x"); n != 2 {
+		t.Errorf("expected 2 attempts, but got %d", n)
+	}
+
+	os.Remove(count)
+	t.Setenv("GSM_TEST_FAILURES", "2")
+	c.Retries = 3
+	rs, err = runGsm(t, Pull, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusChanged})
+	if n := strings.Count(w.run(w.Dir, "cat", count), "x"); n != 3 {
+		t.Errorf("expected 3 attempts, but got %d", n)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"goki.dev/xe"
+)
+
+// ErrFailFast is the cause of the cancellation of the
+// context of [Exec] when a command fails in fail-fast mode.
+var ErrFailFast = errors.New("stopped after failure")
+
+// Exec concurrently runs the given command (eg: gsm exec -- go vet ./...) in each of
+// the Git repositories in the current directory, except for those ignored by the
+// workspace manifest and those not selected by the selector flags. The arguments
+// of the command are Go templates executed with the [Repository], so they can
+// contain {{.Name}}, {{.VanityURL}}, {{.Dir}}, and {{.Version}} (the latest
+// release tag). In fail-fast mode, no more repositories are started after the
+// command fails in one of them. It prints a summary of the results for each
+// repository.
+func Exec(c *Config) error { //gti:add
+	if len(c.Exec.Command) == 0 {
+		return errors.New("missing command to run (eg: gsm exec -- git status)")
+	}
+	tmpls := make([]*template.Template, len(c.Exec.Command))
+	for i, arg := range c.Exec.Command {
+		tmpl, err := template.New("arg").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return fmt.Errorf("invalid template in argument %q: %w", arg, err)
+		}
+		tmpls[i] = tmpl
+	}
+	// we only need to get versions if they are used
+	needVersion := strings.Contains(strings.Join(c.Exec.Command, " "), ".Version")
+
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	reps, err := GitRepositories(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	fctx, fcancel := context.WithCancelCause(ctx)
+	defer fcancel(nil)
+	rs := RunRepositories(fctx, c, reps, func(ctx context.Context, rep *Repository) (Status, error) {
+		dir := filepath.FromSlash(rep.Dir)
+		if needVersion {
+			// repositories without any releases have no version
+			rep.Version, _ = GitFrom(ctx).Describe(ctx, dir)
+		}
+		args := make([]string, len(tmpls))
+		for i, tmpl := range tmpls {
+			b := &strings.Builder{}
+			err := tmpl.Execute(b, rep)
+			if err != nil {
+				return StatusFailed, fmt.Errorf("error expanding argument %q: %w", c.Exec.Command[i], err)
+			}
+			args[i] = b.String()
+		}
+		// the output of the command is the point, so we always print it
+		xc := xe.Major().SetDir(dir).SetStdout(os.Stdout).SetStderr(os.Stderr)
+		err := Run(ctx, xc, args[0], args[1:]...)
+		if err != nil {
+			if c.Exec.FailFast {
+				fcancel(fmt.Errorf("%w in %s", ErrFailFast, rep.Name))
+			}
+			return StatusFailed, err
+		}
+		return StatusOK, nil
+	})
+	return rs.Finish(os.Stdout, c.Format)
+}
@@ -0,0 +1,735 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// FakeGit is an in-memory implementation of [Git] for testing commands without
+// running git. The state of each repository is stored in a [FakeRepository]
+// instead of on the local filesystem, except that cloning a repository makes
+// its directory and an empty .git directory in it, so that it can be found
+// by commands that look for Git repositories on the local filesystem.
+type FakeGit struct {
+
+	// Repositories are the local repositories, keyed by
+	// their slash-separated directories
+	Repositories map[string]*FakeRepository
+
+	// Remotes are the remote repositories, keyed by their URLs
+	Remotes map[string]*FakeRepository
+
+	// Calls are the operations that have been performed
+	// that change repositories (eg: "pull a")
+	Calls []string
+
+	mu sync.Mutex
+}
+
+var _ Git = &FakeGit{}
+
+// FakeRepository is the state of a repository in a [FakeGit].
+type FakeRepository struct {
+
+	// URL is the URL of the remote of the repository (its origin),
+	// which is a key in the Remotes of the FakeGit, or "" if it has none
+	URL string
+
+	// Commits are the commits of the current branch, from oldest to newest
+	Commits []*FakeCommit
+
+	// Tags are the indices in Commits of the
+	// commits that each tag points to, keyed by tag
+	Tags map[string]int
+
+	// Files are the contents of the files in the working tree, keyed by
+	// slash-separated path. If it is nil, the working tree is clean.
+	Files map[string]string
+
+	// Stash is the number of stash entries
+	Stash int
+}
+
+// FakeCommit is a commit in a [FakeRepository].
+type FakeCommit struct {
+
+	// Hash is the hash of the commit
+	Hash string
+
+	// Message is the commit message
+	Message string
+
+	// Files are the contents of all of the files
+	// at the commit, keyed by slash-separated path
+	Files map[string]string
+}
+
+// NewFakeGit returns a new [FakeGit] with no repositories.
+func NewFakeGit() *FakeGit {
+	return &FakeGit{Repositories: map[string]*FakeRepository{}, Remotes: map[string]*FakeRepository{}}
+}
+
+// AddCommit adds a commit with the given message to the repository that
+// changes the given files, keyed by slash-separated path, to the given contents,
+// removing those with empty contents. It returns the new commit.
+func (fr *FakeRepository) AddCommit(message string, files map[string]string) *FakeCommit {
+	cur := map[string]string{}
+	if len(fr.Commits) > 0 {
+		cur = maps.Clone(fr.Commits[len(fr.Commits)-1].Files)
+	}
+	for k, v := range files {
+		if v == "" {
+			delete(cur, k)
+		} else {
+			cur[k] = v
+		}
+	}
+	h := sha1.Sum([]byte(fmt.Sprint(len(fr.Commits), message, cur)))
+	fc := &FakeCommit{Hash: fmt.Sprintf("%x", h), Message: message, Files: cur}
+	fr.Commits = append(fr.Commits, fc)
+	return fc
+}
+
+// head returns the files at the current commit of the repository.
+func (fr *FakeRepository) head() map[string]string {
+	if len(fr.Commits) == 0 {
+		return map[string]string{}
+	}
+	return fr.Commits[len(fr.Commits)-1].Files
+}
+
+// worktree returns the files in the working tree of the repository.
+func (fr *FakeRepository) worktree() map[string]string {
+	if fr.Files == nil {
+		return fr.head()
+	}
+	return fr.Files
+}
+
+// resolve returns the index in Commits of the commit
+// that the given tag, hash, or HEAD refers to.
+func (fr *FakeRepository) resolve(rev string) (int, error) {
+	if rev == "HEAD" && len(fr.Commits) > 0 {
+		return len(fr.Commits) - 1, nil
+	}
+	if i, ok := fr.Tags[rev]; ok {
+		return i, nil
+	}
+	for i, c := range fr.Commits {
+		if c.Hash == rev {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown revision %q", rev)
+}
+
+// isPrefix returns whether the commits of a are a prefix of those of b.
+func isPrefix(a, b *FakeRepository) bool {
+	return len(a.Commits) <= len(b.Commits) && slices.Equal(a.Commits, b.Commits[:len(a.Commits)])
+}
+
+// repository returns the repository in the given directory, recording
+// the given operation if it is not "". It must be called with the lock held.
+func (fg *FakeGit) repository(dir, op string) (*FakeRepository, error) {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	if op != "" {
+		fg.Calls = append(fg.Calls, op+" "+dir)
+	}
+	fr := fg.Repositories[dir]
+	if fr == nil {
+		return nil, fmt.Errorf("fatal: not a git repository: %q", dir)
+	}
+	return fr, nil
+}
+
+// remote returns the remote of the given repository.
+// It must be called with the lock held.
+func (fg *FakeGit) remote(fr *FakeRepository) (*FakeRepository, error) {
+	rfr := fg.Remotes[fr.URL]
+	if rfr == nil {
+		return nil, errors.New("fatal: no upstream configured for the current branch")
+	}
+	return rfr, nil
+}
+
+func (fg *FakeGit) Clone(ctx context.Context, url, dir string) error {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fg.Calls = append(fg.Calls, "clone "+url+" "+filepath.ToSlash(dir))
+	rfr := fg.Remotes[url]
+	if rfr == nil {
+		return fmt.Errorf("fatal: repository %q not found", url)
+	}
+	err := os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	if err != nil {
+		return err
+	}
+	fr := &FakeRepository{URL: url, Commits: slices.Clone(rfr.Commits), Tags: maps.Clone(rfr.Tags)}
+	fg.Repositories[filepath.ToSlash(filepath.Clean(dir))] = fr
+	return nil
+}
+
+func (fg *FakeGit) Pull(ctx context.Context, dir string) error {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "pull")
+	if err != nil {
+		return err
+	}
+	rfr, err := fg.remote(fr)
+	if err != nil {
+		return err
+	}
+	if !isPrefix(fr, rfr) {
+		if isPrefix(rfr, fr) { // we are only ahead
+			return nil
+		}
+		return errors.New("fatal: not possible to fast-forward, aborting")
+	}
+	fr.Commits = slices.Clone(rfr.Commits)
+	if fr.Tags == nil {
+		fr.Tags = map[string]int{}
+	}
+	maps.Copy(fr.Tags, rfr.Tags)
+	return nil
+}
+
+func (fg *FakeGit) Push(ctx context.Context, dir string) error {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "push")
+	if err != nil {
+		return err
+	}
+	rfr, err := fg.remote(fr)
+	if err != nil {
+		return err
+	}
+	if !isPrefix(rfr, fr) {
+		return errors.New("! [rejected] (non-fast-forward)")
+	}
+	rfr.Commits = slices.Clone(fr.Commits)
+	return nil
+}
+
+func (fg *FakeGit) Add(ctx context.Context, dir string, files ...string) error {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	// the index is not modeled, so we only record the operation
+	_, err := fg.repository(dir, "add")
+	return err
+}
+
+func (fg *FakeGit) Commit(ctx context.Context, dir, message string) error {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "commit")
+	if err != nil {
+		return err
+	}
+	// the index is not modeled, so all of the changes are committed
+	if fr.Files == nil || maps.Equal(fr.Files, fr.head()) {
+		return errors.New("nothing to commit, working tree clean")
+	}
+	files := map[string]string{}
+	for k := range fr.head() {
+		files[k] = ""
+	}
+	maps.Copy(files, fr.Files)
+	fr.AddCommit(message, files)
+	fr.Files = nil
+	return nil
+}
+
+func (fg *FakeGit) Tag(ctx context.Context, dir, tag string) error {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "tag")
+	if err != nil {
+		return err
+	}
+	if len(fr.Commits) == 0 {
+		return errors.New("fatal: failed to resolve 'HEAD' as a valid ref")
+	}
+	if _, ok := fr.Tags[tag]; ok {
+		return fmt.Errorf("fatal: tag %q already exists", tag)
+	}
+	if fr.Tags == nil {
+		fr.Tags = map[string]int{}
+	}
+	fr.Tags[tag] = len(fr.Commits) - 1
+	return nil
+}
+
+// Diff returns a simplified diff that contains the old and new
+// contents of each file that is different, sorted by path.
+func (fg *FakeGit) Diff(ctx context.Context, dir, rev string) (string, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return "", err
+	}
+	from := fr.head()
+	if rev != "" {
+		i, err := fr.resolve(rev)
+		if err != nil {
+			return "", err
+		}
+		from = fr.Commits[i].Files
+	}
+	to := fr.worktree()
+	paths := sortedKeys(from)
+	for k := range to {
+		if _, ok := from[k]; !ok {
+			paths = append(paths, k)
+		}
+	}
+	slices.Sort(paths)
+	b := &strings.Builder{}
+	for _, p := range paths {
+		if from[p] == to[p] {
+			continue
+		}
+		fmt.Fprintf(b, "diff --git a/%s b/%s\n-%s\n+%s\n", p, p, from[p], to[p])
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// Patch returns a patch in a simplified format that only [FakeGit.Apply]
+// understands, in which each changed file has a diff --git header followed
+// by its old and new contents quoted on lines starting with - and +.
+func (fg *FakeGit) Patch(ctx context.Context, dir, prefix string) (string, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return "", err
+	}
+	from, to := fr.head(), fr.worktree()
+	all := maps.Clone(from)
+	maps.Copy(all, to)
+	b := &strings.Builder{}
+	for _, p := range sortedKeys(all) {
+		if from[p] == to[p] {
+			continue
+		}
+		fmt.Fprintf(b, "diff --git a/%s%s b/%s%s\n-%s\n+%s\n", prefix, p, prefix, p, strconv.Quote(from[p]), strconv.Quote(to[p]))
+	}
+	return b.String(), nil
+}
+
+// Apply applies a patch returned by [FakeGit.Patch], failing
+// if the old contents of any of the files do not match.
+func (fg *FakeGit) Apply(ctx context.Context, dir, patch string, strip int, check bool) error {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	op := "apply"
+	if check {
+		op = ""
+	}
+	fr, err := fg.repository(dir, op)
+	if err != nil {
+		return err
+	}
+	files := maps.Clone(fr.worktree())
+	lines := strings.Split(patch, "\n")
+	for i := 0; i+2 < len(lines); i += 3 {
+		var p, from, to string
+		_, err := fmt.Sscanf(lines[i], "diff --git a/%s", &p)
+		if err != nil {
+			return fmt.Errorf("invalid patch header %q: %w", lines[i], err)
+		}
+		parts := strings.Split(p, "/")
+		if strip-1 >= len(parts) {
+			return fmt.Errorf("can not remove %d elements from %q", strip-1, p)
+		}
+		p = strings.Join(parts[strip-1:], "/")
+		from, err = strconv.Unquote(strings.TrimPrefix(lines[i+1], "-"))
+		if err == nil {
+			to, err = strconv.Unquote(strings.TrimPrefix(lines[i+2], "+"))
+		}
+		if err != nil {
+			return fmt.Errorf("invalid patch for %q: %w", p, err)
+		}
+		if files[p] != from {
+			return fmt.Errorf("error: %s: patch does not apply", p)
+		}
+		if to == "" {
+			delete(files, p)
+		} else {
+			files[p] = to
+		}
+	}
+	if !check {
+		fr.Files = files
+	}
+	return nil
+}
+
+// Status returns the status of the repository, in which all changes
+// are unstaged, since the index is not modeled, and the upstream is
+// origin/main if the repository has a remote.
+func (fg *FakeGit) Status(ctx context.Context, dir string) (*GitStatus, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	st := &GitStatus{Branch: "main", Stash: fr.Stash}
+	if len(fr.Commits) > 0 {
+		st.Commit = fr.Commits[len(fr.Commits)-1].Hash
+	}
+	if rfr := fg.Remotes[fr.URL]; rfr != nil {
+		st.Upstream = "origin/main"
+		common := 0
+		for common < min(len(fr.Commits), len(rfr.Commits)) && fr.Commits[common] == rfr.Commits[common] {
+			common++
+		}
+		st.Ahead, st.Behind = len(fr.Commits)-common, len(rfr.Commits)-common
+	}
+	head, wt := fr.head(), fr.worktree()
+	for _, p := range sortedKeys(head) {
+		if v, ok := wt[p]; !ok || v != head[p] {
+			st.Unstaged = append(st.Unstaged, p)
+		}
+	}
+	for _, p := range sortedKeys(wt) {
+		if _, ok := head[p]; !ok {
+			st.Untracked = append(st.Untracked, p)
+		}
+	}
+	return st, nil
+}
+
+// Describe returns the tag that points to the newest commit, choosing
+// the greatest tag in lexical order if multiple tags point to it.
+func (fg *FakeGit) Describe(ctx context.Context, dir string) (string, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return "", err
+	}
+	latest, li := "", -1
+	for tag, i := range fr.Tags {
+		if i > li || i == li && tag > latest {
+			latest, li = tag, i
+		}
+	}
+	if latest == "" {
+		return "", ErrNoTags
+	}
+	return latest, nil
+}
+
+func (fg *FakeGit) Head(ctx context.Context, dir string) (string, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return "", err
+	}
+	if len(fr.Commits) == 0 {
+		return "", errors.New("fatal: ambiguous argument 'HEAD': unknown revision")
+	}
+	return fr.Commits[len(fr.Commits)-1].Hash, nil
+}
+
+func (fg *FakeGit) RemoteURL(ctx context.Context, dir, remote string) (string, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return "", err
+	}
+	if remote != "origin" || fr.URL == "" {
+		return "", fmt.Errorf("error: no such remote %q", remote)
+	}
+	return fr.URL, nil
+}
+
+func (fg *FakeGit) Log(ctx context.Context, dir, since string) ([]string, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	i, err := fr.resolve(since)
+	if err != nil {
+		return nil, err
+	}
+	msgs := []string{}
+	for j := len(fr.Commits) - 1; j > i; j-- {
+		msgs = append(msgs, fr.Commits[j].Message)
+	}
+	return msgs, nil
+}
+
+// Commits returns the commits since the given revision, with
+// the files whose contents differ from those of the previous commit.
+func (fg *FakeGit) Commits(ctx context.Context, dir, since string) ([]*GitCommit, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	i, err := fr.resolve(since)
+	if err != nil {
+		return nil, err
+	}
+	commits := []*GitCommit{}
+	for j := len(fr.Commits) - 1; j > i; j-- {
+		fc, prev := fr.Commits[j], fr.Commits[j-1]
+		gc := &GitCommit{Hash: fc.Hash, Message: fc.Message}
+		all := maps.Clone(fc.Files)
+		maps.Copy(all, prev.Files)
+		for _, p := range sortedKeys(all) {
+			if v, ok := fc.Files[p]; !ok || v != prev.Files[p] {
+				gc.Files = append(gc.Files, p)
+			}
+		}
+		commits = append(commits, gc)
+	}
+	return commits, nil
+}
+
+func (fg *FakeGit) Files(ctx context.Context, dir, rev string) ([]string, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	i, err := fr.resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+	return sortedKeys(fr.Commits[i].Files), nil
+}
+
+func (fg *FakeGit) Show(ctx context.Context, dir, rev, fpath string) ([]byte, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+	fr, err := fg.repository(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	i, err := fr.resolve(rev)
+	if err != nil {
+		return nil, err
+	}
+	content, ok := fr.Commits[i].Files[fpath]
+	if !ok {
+		return nil, fmt.Errorf("fatal: path %q does not exist in %q", fpath, rev)
+	}
+	return []byte(content), nil
+}
+
+// fakeWorkspace is a workspace in a temporary directory for unit
+// tests of commands, whose repositories are stored in a [FakeGit].
+type fakeWorkspace struct {
+	t *testing.T
+
+	// Git is the fake Git implementation that stores the repositories
+	Git *FakeGit
+}
+
+// newFakeWorkspace returns a new empty [fakeWorkspace] and
+// changes the current directory to it until the end of the test.
+func newFakeWorkspace(t *testing.T) *fakeWorkspace {
+	chdir(t, t.TempDir())
+	return &fakeWorkspace{t: t, Git: NewFakeGit()}
+}
+
+// Config returns the configuration for running commands in the
+// workspace, which uses its [FakeGit] and the json output format.
+func (w *fakeWorkspace) Config() *Config {
+	c := testSourceConfig()
+	c.Manifest = "gsm.toml"
+	c.Jobs = 4
+	c.Format = "json"
+	c.Output = "block"
+	c.Update = true
+	return c.SetGit(w.Git)
+}
+
+// Context returns a context in which Git operations
+// use the [FakeGit] of the workspace (see [GitFrom]).
+func (w *fakeWorkspace) Context() context.Context {
+	return context.WithValue(context.Background(), gitKey{}, w.Git)
+}
+
+// AddRepository adds a remote repository with the given name whose initial
+// commit has the given files, keyed by slash-separated path, and is tagged
+// v0.1.0. It clones the repository into the directory of the same name,
+// writing the files there so that commands that read them can find them.
+// It returns the local repository.
+func (w *fakeWorkspace) AddRepository(name string, files map[string]string) *FakeRepository {
+	w.t.Helper()
+	rfr := &FakeRepository{Tags: map[string]int{"v0.1.0": 0}}
+	rfr.AddCommit("initial commit", files)
+	w.Git.Remotes[w.Remote(name)] = rfr
+	if err := w.Git.Clone(context.Background(), w.Remote(name), name); err != nil {
+		w.t.Fatal(err)
+	}
+	writeFiles(w.t, name, files)
+	return w.Git.Repositories[name]
+}
+
+// Remote returns the URL of the remote repository with the given name.
+func (w *fakeWorkspace) Remote(name string) string {
+	return "https://github.com/goki/" + name
+}
+
+// Commit adds a commit with the given message and files to the local
+// repository with the given name, as described in [FakeRepository.AddCommit],
+// and writes the files to its directory.
+func (w *fakeWorkspace) Commit(name, message string, files map[string]string) {
+	w.t.Helper()
+	w.Git.Repositories[name].AddCommit(message, files)
+	writeFiles(w.t, name, files)
+}
+
+// fakeToolsEnv is the environment variable containing the URL of the server
+// that runs the fake tools of a [fakeWorkspace] (see [fakeWorkspace.SetTools]).
+const fakeToolsEnv = "GSM_TEST_FAKE_TOOLS"
+
+// fakeTool is an external tool implemented by a test. It is called with the
+// slash-separated directory that the tool is run in, relative to the workspace,
+// and the arguments of the tool, and it returns the standard output of the tool.
+type fakeTool func(dir string, args []string) (string, error)
+
+// fakeToolRequest is a request sent by [runFakeTool] to run a [fakeTool].
+type fakeToolRequest struct {
+
+	// Tool is the name of the tool
+	Tool string
+
+	// Dir is the absolute directory that the tool is run in
+	Dir string
+
+	// Args are the arguments of the tool
+	Args []string
+}
+
+// fakeToolResponse is the response to a [fakeToolRequest].
+type fakeToolResponse struct {
+
+	// Stdout is the standard output of the tool
+	Stdout string
+
+	// Err is the error message of the tool, or "" if it succeeded
+	Err string
+}
+
+// SetTools makes the external commands with the given names run by gsm (eg: go
+// and goki) run the given fake tools in the test instead, so that they can change
+// the repositories in the [FakeGit]. It puts scripts on the PATH that run the test
+// binary, which sends the invocations to a server in the test (see [runFakeTool]).
+func (w *fakeWorkspace) SetTools(tools map[string]fakeTool) {
+	w.t.Helper()
+	if runtime.GOOS == "windows" {
+		w.t.Skip("skipping test with fake tools on windows, since they are shell scripts")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	root, err := filepath.EvalSymlinks(wd)
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		req := &fakeToolRequest{}
+		res := &fakeToolResponse{}
+		err := json.NewDecoder(r.Body).Decode(req)
+		var dir string
+		if err == nil {
+			dir, err = filepath.Rel(root, req.Dir)
+		}
+		if err == nil {
+			tool := tools[req.Tool]
+			if tool == nil {
+				err = fmt.Errorf("unknown tool %q", req.Tool)
+			} else {
+				res.Stdout, err = tool(filepath.ToSlash(dir), req.Args)
+			}
+		}
+		if err != nil {
+			res.Err = err.Error()
+		}
+		json.NewEncoder(rw).Encode(res)
+	}))
+	w.t.Cleanup(srv.Close)
+
+	exe, err := os.Executable()
+	if err != nil {
+		w.t.Fatal(err)
+	}
+	bin := w.t.TempDir()
+	for name := range tools {
+		script := fmt.Sprintf("#!/bin/sh\nexec '%s' %s \"$@\"\n", exe, name)
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			w.t.Fatal(err)
+		}
+	}
+	w.t.Setenv(fakeToolsEnv, srv.URL)
+	w.t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// runFakeTool runs the fake tool with the given name and arguments in the
+// current directory by sending a [fakeToolRequest] to the server at the given
+// URL, printing its standard output. It is called by [TestMain] when the test
+// binary is run by a script made by [fakeWorkspace.SetTools].
+func runFakeTool(url, tool string, args []string) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.EvalSymlinks(wd)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(&fakeToolRequest{Tool: tool, Dir: dir, Args: args})
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	res := &fakeToolResponse{}
+	err = json.NewDecoder(resp.Body).Decode(res)
+	if err != nil {
+		return err
+	}
+	fmt.Print(res.Stdout)
+	if res.Err != "" {
+		return errors.New(res.Err)
+	}
+	return nil
+}
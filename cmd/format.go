@@ -0,0 +1,67 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"goki.dev/grog"
+	"goki.dev/grows/jsons"
+)
+
+// checkFormat returns an error if the given output
+// format is not one of text, json, and ndjson.
+func checkFormat(format string) error {
+	switch format {
+	case "text", "json", "ndjson", "":
+		return nil
+	}
+	return fmt.Errorf("unknown format %q (must be text, json, or ndjson)", format)
+}
+
+// IsJSON returns whether the given output format is json or ndjson.
+func IsJSON(format string) bool {
+	return format == "json" || format == "ndjson"
+}
+
+// quietStdout makes it so that standard output only contains the output
+// of the command itself, which is necessary for JSON output to be parsed.
+// It raises [grog.UserLevel] so that the commands being run and the success
+// message are not printed, while still logging warnings to standard error.
+func quietStdout() {
+	if grog.UserLevel >= slog.LevelError {
+		return
+	}
+	slog.SetDefault(slog.New(grog.NewHandler(os.Stderr, &slog.HandlerOptions{
+		Level: max(grog.UserLevel, slog.LevelWarn),
+	})))
+	grog.UserLevel = slog.LevelError
+}
+
+// WriteJSON writes output in the given JSON-based format to the given
+// writer. If the format is json, it writes the given value as indented
+// JSON. If it is ndjson (newline delimited JSON), it writes each of the
+// given items as compact JSON on its own line, which allows the output
+// to be processed incrementally.
+func WriteJSON[T any](w io.Writer, format string, v any, items []T) error {
+	switch format {
+	case "json":
+		return jsons.WriteIndent(v, w)
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, item := range items {
+			err := enc.Encode(item)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("format %q is not json or ndjson", format)
+}
@@ -14,7 +14,12 @@ import (
 // It should be run in the base goki directory whenever
 // goki.dev/goosi/driver/android/GoNativeActivty.java is updated.
 func Gendex(c *Config) error { //gti:add
-	err := xe.Major().SetDir(filepath.Join("goki", "mobile")).Run("go", "generate")
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	err = Run(ctx, xe.Major().SetDir(filepath.Join("goki", "mobile")), "go", "generate")
 	if err != nil {
 		return err
 	}
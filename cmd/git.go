@@ -0,0 +1,469 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"goki.dev/xe"
+)
+
+// Git is the interface through which gsm performs all of its operations on Git
+// repositories. All directories are paths on the local filesystem relative to
+// the current directory. The standard implementation is [ExecGit]; other
+// implementations can be set with [Config.SetGit] for testing.
+type Git interface {
+
+	// Clone clones the repository at the given URL into the given directory.
+	Clone(ctx context.Context, url, dir string) error
+
+	// Pull pulls the repository in the given directory from its upstream.
+	Pull(ctx context.Context, dir string) error
+
+	// Push pushes the repository in the given directory to its upstream.
+	Push(ctx context.Context, dir string) error
+
+	// Add adds the given files in the repository in the given directory to the index.
+	Add(ctx context.Context, dir string, files ...string) error
+
+	// Commit commits all of the changes to tracked files in the repository in
+	// the given directory (including those not added to the index) with the
+	// given message.
+	Commit(ctx context.Context, dir, message string) error
+
+	// Tag creates an annotated tag with the given name on the
+	// current commit of the repository in the given directory.
+	Tag(ctx context.Context, dir, tag string) error
+
+	// Diff returns the diff of the files in the working tree of the repository
+	// in the given directory from the given revision, or from the index if
+	// the revision is "".
+	Diff(ctx context.Context, dir, rev string) (string, error)
+
+	// Patch returns a patch in the Git diff format (including binary files)
+	// of all of the uncommitted changes in the working tree of the repository
+	// in the given directory relative to its current commit, including those
+	// in untracked files, without changing its index. The paths in the patch
+	// have the given slash-separated prefix after the a/ and b/ prefixes.
+	Patch(ctx context.Context, dir, prefix string) (string, error)
+
+	// Apply applies the given patch returned by Patch to the working
+	// tree of the repository in the given directory, after removing the
+	// given number of leading elements from the paths in it. If check
+	// is true, it only checks whether the patch applies cleanly.
+	Apply(ctx context.Context, dir, patch string, strip int, check bool) error
+
+	// Status returns the status of the repository in the given directory.
+	Status(ctx context.Context, dir string) (*GitStatus, error)
+
+	// Describe returns the latest tag reachable from the current commit of the
+	// repository in the given directory. It returns [ErrNoTags] if there is none.
+	Describe(ctx context.Context, dir string) (string, error)
+
+	// Head returns the hash of the current commit of the repository in the given directory.
+	Head(ctx context.Context, dir string) (string, error)
+
+	// RemoteURL returns the URL of the remote with the given
+	// name of the repository in the given directory.
+	RemoteURL(ctx context.Context, dir, remote string) (string, error)
+
+	// Log returns the messages of the commits of the repository in the
+	// given directory since the given revision, from newest to oldest.
+	Log(ctx context.Context, dir, since string) ([]string, error)
+
+	// Commits returns the commits of the repository in the given
+	// directory since the given revision, from newest to oldest.
+	Commits(ctx context.Context, dir, since string) ([]*GitCommit, error)
+
+	// Files returns the slash-separated paths of all of the files at
+	// the given revision of the repository in the given directory.
+	Files(ctx context.Context, dir, rev string) ([]string, error)
+
+	// Show returns the contents of the file with the given slash-separated
+	// path at the given revision of the repository in the given directory.
+	Show(ctx context.Context, dir, rev, fpath string) ([]byte, error)
+}
+
+// ErrNoTags is the error returned by [Git.Describe]
+// when a repository has no tags to describe it with.
+var ErrNoTags = errors.New("no tags")
+
+// GitStatus is the status of a Git repository, which is
+// based on the output of git status --porcelain=v2.
+type GitStatus struct {
+
+	// Commit is the hash of the current commit,
+	// or "" if there are no commits yet
+	Commit string
+
+	// Branch is the name of the current branch, or "" if HEAD is detached
+	Branch string
+
+	// Upstream is the upstream of the current branch (eg: origin/main),
+	// or "" if it has none
+	Upstream string
+
+	// Ahead is the number of commits that the
+	// current branch is ahead of its upstream
+	Ahead int
+
+	// Behind is the number of commits that the current
+	// branch is behind its upstream as of the last fetch
+	Behind int
+
+	// Stash is the number of stash entries
+	Stash int
+
+	// Staged are the paths of the files with changes in the index
+	Staged []string
+
+	// Unstaged are the paths of the tracked files with
+	// changes in the working tree that are not in the index
+	Unstaged []string
+
+	// Untracked are the paths of the untracked files
+	Untracked []string
+
+	// Unmerged are the paths of the files with unresolved merge conflicts
+	Unmerged []string
+}
+
+// GitCommit is a commit in a Git repository.
+type GitCommit struct {
+
+	// Hash is the hash of the commit
+	Hash string
+
+	// Message is the commit message
+	Message string
+
+	// Files are the slash-separated paths of the files
+	// that the commit added, changed, or deleted
+	Files []string
+}
+
+// gitKey is the context key for [Git]
+type gitKey struct{}
+
+// GitFrom returns the [Git] implementation that commands running with
+// the given context should use, which is [ExecGit] unless another
+// implementation was set with [Config.SetGit].
+func GitFrom(ctx context.Context) Git {
+	if g, ok := ctx.Value(gitKey{}).(Git); ok {
+		return g
+	}
+	return ExecGit{}
+}
+
+// ExecGit is the standard implementation of [Git], which runs the git
+// command with [Run] and [Output]. Operations that change repositories
+// are printed like major commands, and queries like minor commands.
+type ExecGit struct{}
+
+var _ Git = ExecGit{}
+
+func (ExecGit) Clone(ctx context.Context, url, dir string) error {
+	return Run(ctx, xe.Major(), "git", "clone", url, dir)
+}
+
+func (ExecGit) Pull(ctx context.Context, dir string) error {
+	return Run(ctx, xe.Major().SetDir(dir), "git", "pull")
+}
+
+func (ExecGit) Push(ctx context.Context, dir string) error {
+	return Run(ctx, xe.Major().SetDir(dir), "git", "push")
+}
+
+func (ExecGit) Add(ctx context.Context, dir string, files ...string) error {
+	return Run(ctx, xe.Major().SetDir(dir), "git", append([]string{"add", "--"}, files...)...)
+}
+
+func (ExecGit) Commit(ctx context.Context, dir, message string) error {
+	return Run(ctx, xe.Major().SetDir(dir), "git", "commit", "-am", message)
+}
+
+func (ExecGit) Tag(ctx context.Context, dir, tag string) error {
+	return Run(ctx, xe.Major().SetDir(dir), "git", "tag", "-a", tag, "-m", tag)
+}
+
+func (ExecGit) Diff(ctx context.Context, dir, rev string) (string, error) {
+	args := []string{"diff"}
+	if rev != "" {
+		args = append(args, rev)
+	}
+	return Output(ctx, xe.Minor().SetDir(dir), "git", args...)
+}
+
+func (ExecGit) Patch(ctx context.Context, dir, prefix string) (string, error) {
+	// we add all of the files to a temporary index so that untracked
+	// files are included without changing the actual index
+	tmp, err := os.MkdirTemp("", "gsm-patch-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	// the setters of xe configs modify them, so we need a new one for each command
+	xc := func() *xe.Config {
+		return xe.Minor().SetDir(dir).SetEnv("GIT_INDEX_FILE", filepath.Join(tmp, "index"))
+	}
+	args := []string{"diff", "--cached", "--binary", "--src-prefix=a/" + prefix, "--dst-prefix=b/" + prefix}
+	// repositories with no commits have no HEAD, in which
+	// case we diff against the empty index
+	_, err = Output(ctx, xc().SetStderr(nil).SetErrors(nil), "git", "rev-parse", "--verify", "--quiet", "HEAD")
+	if err == nil {
+		err = Run(ctx, xc(), "git", "read-tree", "HEAD")
+		if err != nil {
+			return "", err
+		}
+		args = append(args, "HEAD")
+	}
+	err = Run(ctx, xc(), "git", "add", "--all")
+	if err != nil {
+		return "", err
+	}
+	// we don't print the patch, since it can be very long
+	out, err := Output(ctx, xc().SetStdout(nil), "git", args...)
+	if err != nil || out == "" {
+		return "", err
+	}
+	// the final newline is trimmed by Output, but it is part of the patch
+	return out + "\n", nil
+}
+
+func (ExecGit) Apply(ctx context.Context, dir, patch string, strip int, check bool) error {
+	xc := xe.Major()
+	args := []string{"apply", "-p" + strconv.Itoa(strip)}
+	if check {
+		xc = xe.Minor()
+		args = append(args, "--check")
+	}
+	return Run(ctx, xc.SetDir(dir).SetStdin(strings.NewReader(patch)), "git", args...)
+}
+
+func (ExecGit) Status(ctx context.Context, dir string) (*GitStatus, error) {
+	out, err := Output(ctx, xe.Minor().SetDir(dir), "git", "status", "--porcelain=v2", "--branch", "--show-stash", "-z")
+	if err != nil {
+		return nil, err
+	}
+	return ParseGitStatus(out)
+}
+
+func (ExecGit) Describe(ctx context.Context, dir string) (string, error) {
+	// we don't print errors, since not having any tags is common, and we use
+	// the C locale so that the messages we check for are not translated
+	xc := xe.Minor().SetDir(dir).SetStderr(nil).SetErrors(nil).SetEnv("LC_ALL", "C")
+	tag, err := Output(ctx, xc, "git", "describe", "--abbrev=0")
+	ce := &CommandError{}
+	if errors.As(err, &ce) && (strings.Contains(ce.Stderr, "No names found") || strings.Contains(ce.Stderr, "can describe")) {
+		return "", ErrNoTags
+	}
+	return tag, err
+}
+
+func (ExecGit) Head(ctx context.Context, dir string) (string, error) {
+	return Output(ctx, xe.Minor().SetDir(dir), "git", "rev-parse", "HEAD")
+}
+
+func (ExecGit) RemoteURL(ctx context.Context, dir, remote string) (string, error) {
+	return Output(ctx, xe.Minor().SetDir(dir), "git", "remote", "get-url", remote)
+}
+
+func (ExecGit) Log(ctx context.Context, dir, since string) ([]string, error) {
+	out, err := Output(ctx, xe.Minor().SetDir(dir), "git", "log", since+"..HEAD", "--format=%B%x00")
+	if err != nil {
+		return nil, err
+	}
+	msgs := []string{}
+	for _, msg := range strings.Split(out, "\x00") {
+		msg = strings.TrimSpace(msg)
+		if msg != "" {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+func (ExecGit) Commits(ctx context.Context, dir, since string) ([]*GitCommit, error) {
+	// each commit starts with a record separator and has a unit separator
+	// between its hash and message, and the message is followed by the
+	// NUL-separated files
+	out, err := Output(ctx, xe.Minor().SetDir(dir), "git", "log", since+"..HEAD", "--format=%x1e%H%x1f%B", "--name-only", "-z")
+	if err != nil {
+		return nil, err
+	}
+	commits := []*GitCommit{}
+	for _, rec := range strings.Split(out, "\x1e") {
+		hash, rest, ok := strings.Cut(rec, "\x1f")
+		if !ok {
+			continue
+		}
+		msg, files, _ := strings.Cut(rest, "\x00")
+		gc := &GitCommit{Hash: hash, Message: strings.TrimSpace(msg)}
+		for _, f := range strings.Split(strings.TrimPrefix(files, "\n"), "\x00") {
+			if f != "" {
+				gc.Files = append(gc.Files, f)
+			}
+		}
+		commits = append(commits, gc)
+	}
+	return commits, nil
+}
+
+func (ExecGit) Files(ctx context.Context, dir, rev string) ([]string, error) {
+	out, err := Output(ctx, xe.Minor().SetDir(dir), "git", "ls-tree", "-r", "--name-only", rev)
+	if err != nil || out == "" {
+		return nil, err
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+func (ExecGit) Show(ctx context.Context, dir, rev, fpath string) ([]byte, error) {
+	out, err := Output(ctx, xe.Minor().SetDir(dir), "git", "show", rev+":"+fpath)
+	return []byte(out), err
+}
+
+// ParseGitStatus parses the given output of
+// git status --porcelain=v2 --branch --show-stash -z.
+func ParseGitStatus(out string) (*GitStatus, error) {
+	st := &GitStatus{}
+	fields := strings.Split(out, "\x00")
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if f == "" {
+			continue
+		}
+		switch f[0] {
+		case '#':
+			key, val, _ := strings.Cut(strings.TrimPrefix(f, "# "), " ")
+			switch key {
+			case "branch.oid":
+				if val != "(initial)" {
+					st.Commit = val
+				}
+			case "branch.head":
+				if val != "(detached)" {
+					st.Branch = val
+				}
+			case "branch.upstream":
+				st.Upstream = val
+			case "stash":
+				_, err := fmt.Sscanf(val, "%d", &st.Stash)
+				if err != nil {
+					return nil, fmt.Errorf("invalid stash header %q: %w", val, err)
+				}
+			case "branch.ab":
+				_, err := fmt.Sscanf(val, "+%d -%d", &st.Ahead, &st.Behind)
+				if err != nil {
+					return nil, fmt.Errorf("invalid branch.ab header %q: %w", val, err)
+				}
+			}
+		case '1', '2', 'u':
+			// the number of fields before the path for each type of entry
+			n := map[byte]int{'1': 8, '2': 9, 'u': 10}[f[0]]
+			parts := strings.SplitN(f, " ", n+1)
+			if len(parts) != n+1 {
+				return nil, fmt.Errorf("invalid status entry %q", f)
+			}
+			xy, fpath := parts[1], parts[n]
+			if f[0] == '2' {
+				i++ // the original path of renames is the next field
+			}
+			if f[0] == 'u' {
+				st.Unmerged = append(st.Unmerged, fpath)
+				continue
+			}
+			if xy[0] != '.' {
+				st.Staged = append(st.Staged, fpath)
+			}
+			if xy[1] != '.' {
+				st.Unstaged = append(st.Unstaged, fpath)
+			}
+		case '?':
+			st.Untracked = append(st.Untracked, strings.TrimPrefix(f, "? "))
+		case '!': // ignored files
+		default:
+			return nil, fmt.Errorf("invalid status entry %q", f)
+		}
+	}
+	return st, nil
+}
+
+// Detached returns whether HEAD is detached, meaning that
+// there is a current commit but no current branch.
+func (st *GitStatus) Detached() bool {
+	return st.Branch == "" && st.Commit != ""
+}
+
+// Change is a category of local changes in a Git repository
+// that need to be updated in version control.
+type Change string
+
+const (
+	// ChangeStaged indicates that there are changes in the index
+	ChangeStaged Change = "staged"
+
+	// ChangeUnstaged indicates that there are changes to tracked
+	// files in the working tree that are not in the index
+	ChangeUnstaged Change = "unstaged"
+
+	// ChangeUntracked indicates that there are untracked files
+	ChangeUntracked Change = "untracked"
+
+	// ChangeUnmerged indicates that there are unresolved merge conflicts
+	ChangeUnmerged Change = "unmerged"
+
+	// ChangeAhead indicates that the current branch has
+	// commits that have not been pushed to its upstream
+	ChangeAhead Change = "ahead"
+
+	// ChangeDiverged indicates that the current branch and its upstream
+	// both have commits that the other does not, so it can not be
+	// pushed without first being merged or rebased
+	ChangeDiverged Change = "diverged"
+
+	// ChangeNoUpstream indicates that the current branch has commits
+	// but no upstream, so none of its commits have been pushed
+	ChangeNoUpstream Change = "no-upstream"
+)
+
+// Changes returns the categories of local changes in the repository,
+// in the order in which they are declared. A branch that is both ahead
+// of and behind its upstream is diverged rather than ahead, and a
+// detached HEAD is not considered to be a change on its own.
+func (st *GitStatus) Changes() []Change {
+	changes := []Change{}
+	if len(st.Staged) > 0 {
+		changes = append(changes, ChangeStaged)
+	}
+	if len(st.Unstaged) > 0 {
+		changes = append(changes, ChangeUnstaged)
+	}
+	if len(st.Untracked) > 0 {
+		changes = append(changes, ChangeUntracked)
+	}
+	if len(st.Unmerged) > 0 {
+		changes = append(changes, ChangeUnmerged)
+	}
+	switch {
+	case st.Ahead > 0 && st.Behind > 0:
+		changes = append(changes, ChangeDiverged)
+	case st.Ahead > 0:
+		changes = append(changes, ChangeAhead)
+	case st.Branch != "" && st.Commit != "" && st.Upstream == "":
+		changes = append(changes, ChangeNoUpstream)
+	}
+	return changes
+}
+
+// Clean returns whether the repository has no local changes (see [GitStatus.Changes]).
+func (st *GitStatus) Clean() bool {
+	return len(st.Changes()) == 0
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"goki.dev/grows/jsons"
+)
+
+// Graph prints the dependency graph of all of the Goki Git repositories
+// in the current directory in the configured format (dot, mermaid, or json),
+// optionally highlighting changed repositories, labeling dependencies with
+// the versions required in go.mod files, and restricting the graph to the
+// dependency closure of a repository. Only the repositories selected by the
+// selector flags are included.
+func Graph(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	g, err := LocalDependencyGraph(ctx, c)
+	if err != nil {
+		return err
+	}
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	sel, err := NewSelection(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	reps, err := SelectRepositories(ctx, sel, g.Repositories)
+	if err != nil {
+		return err
+	}
+	if c.Graph.Root != "" {
+		root := g.RepositoryByName(c.Graph.Root)
+		if root == nil {
+			return fmt.Errorf("repository %q not found", c.Graph.Root)
+		}
+		reps = slices.DeleteFunc(g.Closure(root), func(rep *Repository) bool { return !slices.Contains(reps, rep) })
+	}
+	if c.Graph.Changed {
+		err := ForEach(ctx, c, reps, func(rep *Repository) error {
+			return UpdateChanged(ctx, rep)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	switch c.Graph.Format {
+	case "dot", "":
+		return WriteGraphDOT(os.Stdout, g, reps, c.Graph.Versions)
+	case "mermaid":
+		return WriteGraphMermaid(os.Stdout, g, reps, c.Graph.Versions)
+	case "json":
+		return WriteGraphJSON(os.Stdout, g, reps)
+	}
+	return fmt.Errorf("unknown graph format %q (must be dot, mermaid, or json)", c.Graph.Format)
+}
+
+// LocalDependencyGraph returns the [DependencyGraph] of all of the
+// local repositories returned by [GetLocalRepositories], excluding
+// those ignored by the workspace manifest. Any imports that are
+// missing from the graph are logged as warnings.
+func LocalDependencyGraph(ctx context.Context, c *Config) (*DependencyGraph, error) {
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	all, err := GetLocalRepositories(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing packages: %w", err)
+	}
+	reps := []*Repository{}
+	for _, rep := range all {
+		if !m.Ignored(rep.Name) {
+			reps = append(reps, rep)
+		}
+	}
+	g, err := NewDependencyGraph(reps)
+	if err != nil {
+		slog.Warn("dependency graph is incomplete", "err", err)
+	}
+	return g, nil
+}
+
+// graphEdges calls the given function for each dependency in the given
+// dependency graph between two of the given repositories.
+func graphEdges(g *DependencyGraph, reps []*Repository, fun func(rep, dep *Repository)) {
+	for _, rep := range reps {
+		for _, dep := range g.Dependencies[rep] {
+			if slices.Contains(reps, dep) {
+				fun(rep, dep)
+			}
+		}
+	}
+}
+
+// WriteGraphDOT writes the given repositories of the given dependency graph
+// to the given writer in the Graphviz DOT format, filling changed repositories
+// and labeling dependencies with their required versions if versions is true.
+func WriteGraphDOT(w io.Writer, g *DependencyGraph, reps []*Repository, versions bool) error {
+	b := &strings.Builder{}
+	b.WriteString("digraph gsm {\n")
+	for _, rep := range reps {
+		fmt.Fprintf(b, "\t%q", rep.Name)
+		if rep.Changed {
+			b.WriteString(" [style=filled, fillcolor=yellow]")
+		}
+		b.WriteString(";\n")
+	}
+	graphEdges(g, reps, func(rep, dep *Repository) {
+		fmt.Fprintf(b, "\t%q -> %q", rep.Name, dep.Name)
+		if versions {
+			fmt.Fprintf(b, " [label=%q]", strings.Join(rep.RequiredVersions(dep), ", "))
+		}
+		b.WriteString(";\n")
+	})
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteGraphMermaid writes the given repositories of the given dependency graph
+// to the given writer in the Mermaid flowchart format, highlighting changed
+// repositories and labeling dependencies with their required versions if
+// versions is true.
+func WriteGraphMermaid(w io.Writer, g *DependencyGraph, reps []*Repository, versions bool) error {
+	// we use indices as node IDs since names can contain characters
+	// (like dots) that are not valid in Mermaid node IDs
+	id := func(rep *Repository) string {
+		return fmt.Sprintf("r%d", slices.Index(reps, rep))
+	}
+	b := &strings.Builder{}
+	b.WriteString("graph LR\n")
+	for _, rep := range reps {
+		fmt.Fprintf(b, "\t%s[%q]", id(rep), rep.Name)
+		if rep.Changed {
+			b.WriteString(":::changed")
+		}
+		b.WriteString("\n")
+	}
+	graphEdges(g, reps, func(rep, dep *Repository) {
+		if versions {
+			fmt.Fprintf(b, "\t%s -->|%s| %s\n", id(rep), strings.Join(rep.RequiredVersions(dep), ", "), id(dep))
+			return
+		}
+		fmt.Fprintf(b, "\t%s --> %s\n", id(rep), id(dep))
+	})
+	b.WriteString("\tclassDef changed fill:#ff0\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// GraphJSON is the JSON representation of a [DependencyGraph]
+// written by [WriteGraphJSON].
+type GraphJSON struct {
+
+	// Repositories are the repositories in the graph
+	Repositories []*Repository
+
+	// Dependencies are the dependencies between the repositories
+	Dependencies []GraphJSONDependency
+}
+
+// GraphJSONDependency is the JSON representation
+// of one dependency in a [GraphJSON].
+type GraphJSONDependency struct {
+
+	// From is the name of the dependent repository
+	From string
+
+	// To is the name of the dependency repository
+	To string
+
+	// Versions are the versions of the dependency
+	// required in the go.mod files of the dependent
+	Versions []string
+}
+
+// WriteGraphJSON writes the given repositories of the given dependency
+// graph to the given writer in JSON format as a [GraphJSON].
+func WriteGraphJSON(w io.Writer, g *DependencyGraph, reps []*Repository) error {
+	gj := &GraphJSON{Repositories: reps, Dependencies: []GraphJSONDependency{}}
+	graphEdges(g, reps, func(rep, dep *Repository) {
+		gj.Dependencies = append(gj.Dependencies, GraphJSONDependency{From: rep.Name, To: dep.Name, Versions: rep.RequiredVersions(dep)})
+	})
+	return jsons.WriteIndent(gj, w)
+}
@@ -16,9 +16,38 @@ var _ = gti.AddType(&gti.Type{
 		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
 	},
 	Fields: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
-		{"Update", &gti.Field{Name: "Update", Type: "bool", LocalType: "bool", Doc: "Update is whether to update dependencies and tidy modules\nwhen doing a release cycle. It should only be turned off\nin rare cases in which updating dependencies or tidying\nmodules would cause problems or is not possible.", Directives: gti.Directives{}, Tag: "cmd:\"release\" def:\"true\""}},
+		{"Manifest", &gti.Field{Name: "Manifest", Type: "string", LocalType: "string", Doc: "Manifest is the path of the workspace manifest file, which\nspecifies the repositories that each command should skip.\nIf it does not exist, a default manifest is used.", Directives: gti.Directives{}, Tag: "def:\"gsm.toml\""}},
+		{"Vanity", &gti.Field{Name: "Vanity", Type: "string", LocalType: "string", Doc: "Vanity is the vanity import path prefix of the Go modules that\ngsm operates on (eg: goki.dev). Only modules and dependencies\nwith this prefix are treated as Goki modules.", Directives: gti.Directives{}, Tag: "def:\"goki.dev\""}},
+		{"Host", &gti.Field{Name: "Host", Type: "string", LocalType: "string", Doc: "Host is the domain of the Git host of the repositories (eg: github.com)", Directives: gti.Directives{}, Tag: "def:\"github.com\""}},
+		{"Org", &gti.Field{Name: "Org", Type: "string", LocalType: "string", Doc: "Org is the name of the organization or user on the Git host\nthat owns the repositories (eg: goki)", Directives: gti.Directives{}, Tag: "def:\"goki\""}},
+		{"Protocol", &gti.Field{Name: "Protocol", Type: "string", LocalType: "string", Doc: "Protocol is the protocol used to clone repositories from\nthe Git host: \"https\" or \"ssh\"", Directives: gti.Directives{}, Tag: "def:\"https\""}},
+		{"Source", &gti.Field{Name: "Source", Type: "string", LocalType: "string", Doc: "Source is the source of the repositories that clone operates on:\n\"website\" (a repositories page like https://goki.dev/repositories),\n\"manifest\" (the repositories listed in the workspace manifest),\n\"work\" (the modules used in a go.work file), or \"github\"\n(a GitHub organization JSON repository listing).", Directives: gti.Directives{}, Tag: "def:\"website\""}},
+		{"SourceURL", &gti.Field{Name: "SourceURL", Type: "string", LocalType: "string", Doc: "SourceURL is the URL (or file path for the \"work\" source)\nof the repository source. If it is unset, the default\nlocation for the source is used.", Directives: gti.Directives{}, Tag: ""}},
+		{"Refresh", &gti.Field{Name: "Refresh", Type: "bool", LocalType: "bool", Doc: "Refresh is whether to require fetching the repository list from\nthe website, instead of falling back on the cached list when the\nwebsite can not be reached.", Directives: gti.Directives{}, Tag: ""}},
+		{"Jobs", &gti.Field{Name: "Jobs", Type: "int", LocalType: "int", Doc: "Jobs is the maximum number of repositories to process\nconcurrently. If it is 0, the number of CPUs is used.", Directives: gti.Directives{}, Tag: ""}},
+		{"Timeout", &gti.Field{Name: "Timeout", Type: "string", LocalType: "string", Doc: "Timeout is the maximum duration of each external command run by\ngsm (eg: 10m or 30s), after which the command is stopped. If it\nis 0, there is no limit.", Directives: gti.Directives{}, Tag: "def:\"10m\""}},
+		{"Retries", &gti.Field{Name: "Retries", Type: "int", LocalType: "int", Doc: "Retries is the maximum number of times to retry network Git\noperations (like cloning and pulling) that fail because of\ntransient problems like connection resets and server errors.", Directives: gti.Directives{}, Tag: "def:\"3\""}},
+		{"RetryDelay", &gti.Field{Name: "RetryDelay", Type: "string", LocalType: "string", Doc: "RetryDelay is the delay before the first retry of a network Git\noperation (eg: 1s or 500ms), which doubles after each retry.", Directives: gti.Directives{}, Tag: "def:\"1s\""}},
+		{"Format", &gti.Field{Name: "Format", Type: "string", LocalType: "string", Doc: "Format is the output format of commands that report on\nrepositories: text (human-readable tables), json (a single\nJSON object), or ndjson (one JSON object per line for each\nrepository, which can be processed incrementally).", Directives: gti.Directives{}, Tag: "cmd:\"changed,pull,clone,release,list,status,changelog,apply,commit,push\" def:\"text\""}},
+		{"Output", &gti.Field{Name: "Output", Type: "string", LocalType: "string", Doc: "Output is how the output of the external commands run concurrently on\neach repository is printed, with each line prefixed with the name of the\nrepository: block (all together once the repository is done, so that\nthe output of different repositories does not interleave) or stream\n(as soon as each command finishes).", Directives: gti.Directives{}, Tag: "cmd:\"changed,pull,clone,exec,commit,push\" def:\"block\""}},
+		{"LogDir", &gti.Field{Name: "LogDir", Type: "string", LocalType: "string", Doc: "LogDir is the directory in which to write a log file for each\nrepository containing all of the external commands run on it and\ntheir output, for investigating failures. Each run writes its logs\nto a new subdirectory named by the time of the run. If it is \"\",\nno log files are written.", Directives: gti.Directives{}, Tag: "cmd:\"changed,pull,clone,exec,commit,push\""}},
+		{"Repos", &gti.Field{Name: "Repos", Type: "[]string", LocalType: "[]string", Doc: "Repos are the names or glob patterns (in the format of [path.Match])\nof the repositories to run commands on. If it is empty, commands\nrun on all repositories.", Directives: gti.Directives{}, Tag: ""}},
+		{"Exclude", &gti.Field{Name: "Exclude", Type: "[]string", LocalType: "[]string", Doc: "Exclude are the names or glob patterns (in the format of\n[path.Match]) of repositories to not run commands on.", Directives: gti.Directives{}, Tag: ""}},
+		{"Group", &gti.Field{Name: "Group", Type: "[]string", LocalType: "[]string", Doc: "Group are the groups specified in the workspace manifest of\nthe repositories to run commands on. If it is empty, commands\nrun on repositories in all groups.", Directives: gti.Directives{}, Tag: ""}},
+		{"ChangedOnly", &gti.Field{Name: "ChangedOnly", Type: "bool", LocalType: "bool", Doc: "ChangedOnly is whether to only run commands on repositories that\nhave changes that need to be updated in version control.", Directives: gti.Directives{}, Tag: ""}},
+		{"Closure", &gti.Field{Name: "Closure", Type: "string", LocalType: "string", Doc: "Closure is the name of a repository to restrict commands to the\ndependency closure of, which consists of the repository and all\nof the repositories it directly or indirectly depends on.", Directives: gti.Directives{}, Tag: ""}},
+		{"Update", &gti.Field{Name: "Update", Type: "bool", LocalType: "bool", Doc: "Update is whether to update dependencies and tidy modules\nwhen doing a release cycle. It should only be turned off\nin rare cases in which updating dependencies or tidying\nmodules would cause problems or is not possible. It also\ndetermines whether changelogs include Goki import updates.", Directives: gti.Directives{}, Tag: "cmd:\"release,changelog\" def:\"true\""}},
+		{"DryRun", &gti.Field{Name: "DryRun", Type: "bool", LocalType: "bool", Doc: "DryRun is whether to only print the plan for a release cycle\n(the changed repositories, the release order, the Goki imports\nthat will be updated, and the predicted versions) without\nchanging any repositories.", Directives: gti.Directives{}, Tag: "cmd:\"release\""}},
+		{"Bump", &gti.Field{Name: "Bump", Type: "map[string]string", LocalType: "map[string]string", Doc: "Bump overrides the inferred version bump (patch, minor, or major)\nfor specific repositories when releasing (eg: gi=minor,gti=patch).\nThe bump for other repositories is inferred from the conventional\ncommit messages and exported API changes since their last release.", Directives: gti.Directives{}, Tag: "cmd:\"release,changelog\""}},
 		{"Repository", &gti.Field{Name: "Repository", Type: "string", LocalType: "string", Doc: "The name of the repository to create a vanity import site for.\nA major version suffix can be added to the end of the repository name\n(eg: \"gi/v2\")", Directives: gti.Directives{}, Tag: "cmd:\"new-vanity\" posarg:\"0\""}},
+		{"Module", &gti.Field{Name: "Module", Type: "string", LocalType: "string", Doc: "The module to print the dependents of, specified as a module\npath (eg: goki.dev/laser) or a repository name (eg: laser)", Directives: gti.Directives{}, Tag: "cmd:\"dependents\" posarg:\"0\""}},
 		{"IOSFramework", &gti.Field{Name: "IOSFramework", Type: "goki.dev/gsm/cmd.IOSFramework", LocalType: "IOSFramework", Doc: "the config info for the make-ios-framework command", Directives: gti.Directives{}, Tag: "cmd:\"make-ios-framework\""}},
+		{"Graph", &gti.Field{Name: "Graph", Type: "goki.dev/gsm/cmd.GraphConfig", LocalType: "GraphConfig", Doc: "the config info for the graph command", Directives: gti.Directives{}, Tag: "cmd:\"graph\""}},
+		{"Exec", &gti.Field{Name: "Exec", Type: "goki.dev/gsm/cmd.ExecConfig", LocalType: "ExecConfig", Doc: "the config info for the exec command", Directives: gti.Directives{}, Tag: "cmd:\"exec\""}},
+		{"Diff", &gti.Field{Name: "Diff", Type: "goki.dev/gsm/cmd.DiffConfig", LocalType: "DiffConfig", Doc: "the config info for the diff command", Directives: gti.Directives{}, Tag: "cmd:\"diff\""}},
+		{"Apply", &gti.Field{Name: "Apply", Type: "goki.dev/gsm/cmd.ApplyConfig", LocalType: "ApplyConfig", Doc: "the config info for the apply command", Directives: gti.Directives{}, Tag: "cmd:\"apply\""}},
+		{"Commit", &gti.Field{Name: "Commit", Type: "goki.dev/gsm/cmd.CommitConfig", LocalType: "CommitConfig", Doc: "the config info for the commit command", Directives: gti.Directives{}, Tag: "cmd:\"commit\""}},
+		{"git", &gti.Field{Name: "git", Type: "goki.dev/gsm/cmd.Git", LocalType: "Git", Doc: "git is the Git implementation to use, or nil to use [ExecGit];\nit is not associated with any commands so that it is not a flag", Directives: gti.Directives{}, Tag: "cmd:\"-\""}},
 	}),
 	Embeds:  ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{}),
 	Methods: ordmap.Make([]ordmap.KeyVal[string, *gti.Method]{}),
@@ -42,9 +71,104 @@ var _ = gti.AddType(&gti.Type{
 	Methods: ordmap.Make([]ordmap.KeyVal[string, *gti.Method]{}),
 })
 
+var _ = gti.AddType(&gti.Type{
+	Name:      "goki.dev/gsm/cmd.GraphConfig",
+	ShortName: "cmd.GraphConfig",
+	IDName:    "graph-config",
+	Doc:       "",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Fields: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"Format", &gti.Field{Name: "Format", Type: "string", LocalType: "string", Doc: "the format to print the graph in: dot, mermaid, or json", Directives: gti.Directives{}, Tag: "def:\"dot\""}},
+		{"Changed", &gti.Field{Name: "Changed", Type: "bool", LocalType: "bool", Doc: "whether to highlight the repositories that have changed since their last release", Directives: gti.Directives{}, Tag: ""}},
+		{"Versions", &gti.Field{Name: "Versions", Type: "bool", LocalType: "bool", Doc: "whether to label dependencies with the versions required in go.mod files", Directives: gti.Directives{}, Tag: ""}},
+		{"Root", &gti.Field{Name: "Root", Type: "string", LocalType: "string", Doc: "the name of a repository to restrict the graph to the dependency closure of", Directives: gti.Directives{}, Tag: "posarg:\"0\" required:\"-\""}},
+	}),
+	Embeds:  ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{}),
+	Methods: ordmap.Make([]ordmap.KeyVal[string, *gti.Method]{}),
+})
+
+var _ = gti.AddType(&gti.Type{
+	Name:      "goki.dev/gsm/cmd.ExecConfig",
+	ShortName: "cmd.ExecConfig",
+	IDName:    "exec-config",
+	Doc:       "",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Fields: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"Command", &gti.Field{Name: "Command", Type: "[]string", LocalType: "[]string", Doc: "the command to run in each repository and its arguments,\nwhich can contain templates like {{.Name}} (eg: gsm exec -- git log -1)", Directives: gti.Directives{}, Tag: "posarg:\"all\""}},
+		{"FailFast", &gti.Field{Name: "FailFast", Type: "bool", LocalType: "bool", Doc: "whether to stop starting the command in more repositories\nonce it fails in one of them, instead of continuing", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Embeds:  ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{}),
+	Methods: ordmap.Make([]ordmap.KeyVal[string, *gti.Method]{}),
+})
+
+var _ = gti.AddType(&gti.Type{
+	Name:      "goki.dev/gsm/cmd.DiffConfig",
+	ShortName: "cmd.DiffConfig",
+	IDName:    "diff-config",
+	Doc:       "",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Fields: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"File", &gti.Field{Name: "File", Type: "string", LocalType: "string", Doc: "the file to write the patch bundle to instead of printing it", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Embeds:  ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{}),
+	Methods: ordmap.Make([]ordmap.KeyVal[string, *gti.Method]{}),
+})
+
+var _ = gti.AddType(&gti.Type{
+	Name:      "goki.dev/gsm/cmd.ApplyConfig",
+	ShortName: "cmd.ApplyConfig",
+	IDName:    "apply-config",
+	Doc:       "",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Fields: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"File", &gti.Field{Name: "File", Type: "string", LocalType: "string", Doc: "the patch bundle file written by the diff command\nto apply, or - to read it from standard input", Directives: gti.Directives{}, Tag: "posarg:\"0\""}},
+	}),
+	Embeds:  ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{}),
+	Methods: ordmap.Make([]ordmap.KeyVal[string, *gti.Method]{}),
+})
+
+var _ = gti.AddType(&gti.Type{
+	Name:      "goki.dev/gsm/cmd.CommitConfig",
+	ShortName: "cmd.CommitConfig",
+	IDName:    "commit-config",
+	Doc:       "",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Fields: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"Message", &gti.Field{Name: "Message", Type: "string", LocalType: "string", Doc: "the commit message", Directives: gti.Directives{}, Tag: "flag:\"m,message\""}},
+		{"All", &gti.Field{Name: "All", Type: "bool", LocalType: "bool", Doc: "whether to also commit untracked files", Directives: gti.Directives{}, Tag: "flag:\"a,all\""}},
+		{"Interactive", &gti.Field{Name: "Interactive", Type: "bool", LocalType: "bool", Doc: "whether to ask whether to commit in each changed repository", Directives: gti.Directives{}, Tag: "flag:\"i,interactive\""}},
+	}),
+	Embeds:  ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{}),
+	Methods: ordmap.Make([]ordmap.KeyVal[string, *gti.Method]{}),
+})
+
 var _ = gti.AddFunc(&gti.Func{
 	Name: "goki.dev/gsm/cmd.Changed",
-	Doc:  "Changed concurrently prints all of the repositories that have been changed\nand need to be updated in version control.",
+	Doc:  "Changed concurrently checks which of the repositories in the current directory\nhave been changed and need to be updated in version control, except for those\nignored by the workspace manifest and those not selected by the selector flags,\nand prints a summary of the results for each repository, in which the changed\nrepositories are marked as changed along with the categories of their changes:\nstaged, unstaged, untracked, and unmerged files, commits ahead of the upstream,\na branch that has diverged from its upstream, and a branch with no upstream.",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.Changelog",
+	Doc:  "Changelog prints a preview of what the next release cycle will ship for each of\nthe repositories that would be released by [Release] and have changed since their\nlatest version tag (see [RepositoryHasChanged]): the commits since that tag, grouped\nby conventional commit type (https://www.conventionalcommits.org) with the files\nthat each one touched, and the Goki imports that the release will update to new\nversions (if the update flag is on). It does not change any repositories.",
 	Directives: gti.Directives{
 		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
 	},
@@ -58,7 +182,49 @@ var _ = gti.AddFunc(&gti.Func{
 
 var _ = gti.AddFunc(&gti.Func{
 	Name: "goki.dev/gsm/cmd.Clone",
-	Doc:  "Clone concurrently clones all of the Goki Go repositories into the current directory.\nIt does not clone repositories that the user already has in the current directory.",
+	Doc:  "Clone concurrently clones all of the Goki Go repositories from the configured\nrepository source into the current directory, using the configured protocol.\nIt does not clone repositories that the user already has in the current directory.\nIt uses the remote URLs specified in the workspace manifest when they are present,\nalso cloning any repositories that are only listed in the manifest. Clones that fail\nbecause of transient network problems are retried. It prints a summary of the\nresults for each repository, in which cloned repositories are marked as changed\nand existing ones as skipped.",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.Commit",
+	Doc:  "Commit commits the changes in each of the changed Git repositories in the current\ndirectory (see [LocalChanges]) with the given message, except for those ignored by\nthe workspace manifest and those not selected by the selector flags. It commits\nall of the changes to tracked files, and also untracked files if the all flag is\non. In interactive mode, it asks whether to commit in each changed repository\nfirst. It prints a summary of the results for each repository, in which the\nrepositories that were committed in are marked as changed and those that were\nnot chosen or only have untracked files are marked as skipped, along with the\ncategories of the changes that each repository had, like [Changed].",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.Dependents",
+	Doc:  "Dependents prints all of the Goki Git repositories in the current directory\nthat directly or indirectly depend on the config module, which can be specified\nas a module path or a repository name. For each dependent, it prints the versions\nof the module required in its go.mod files and whether they are behind the\nlatest version tag of the module. Only the dependents selected by the selector\nflags are printed.",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.Exec",
+	Doc:  "Exec concurrently runs the given command (eg: gsm exec -- go vet ./...) in each of\nthe Git repositories in the current directory, except for those ignored by the\nworkspace manifest and those not selected by the selector flags. The arguments\nof the command are Go templates executed with the [Repository], so they can\ncontain {{.Name}}, {{.VanityURL}}, {{.Dir}}, and {{.Version}} (the latest\nrelease tag). In fail-fast mode, no more repositories are started after the\ncommand fails in one of them. It prints a summary of the results for each\nrepository.",
 	Directives: gti.Directives{
 		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
 	},
@@ -84,6 +250,20 @@ var _ = gti.AddFunc(&gti.Func{
 	}),
 })
 
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.Graph",
+	Doc:  "Graph prints the dependency graph of all of the Goki Git repositories\nin the current directory in the configured format (dot, mermaid, or json),\noptionally highlighting changed repositories, labeling dependencies with\nthe versions required in go.mod files, and restricting the graph to the\ndependency closure of a repository. Only the repositories selected by the\nselector flags are included.",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
 var _ = gti.AddFunc(&gti.Func{
 	Name: "goki.dev/gsm/cmd.InstallTools",
 	Doc:  "InstallTools installs all of the Goki tools required for development on\nthe Goki codebase (goki, gsm, gtigen, and enumgen). It should be run in a\ndirectory containing all of the goki repositories (set up with gsm clone),\nand with a go.work file contianing all of those repositories (set up with gsm work).",
@@ -98,6 +278,20 @@ var _ = gti.AddFunc(&gti.Func{
 	}),
 })
 
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.List",
+	Doc:  "List prints all of the Goki Git repositories containing Go modules in the\ncurrent directory, except for those ignored by the workspace manifest and\nthose not selected by the selector flags, in the configured output format.\nIn the text format, it prints a table of the names, directories, and modules\nof the repositories; in the json and ndjson formats, it prints all of the\nfields of the repositories.",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
 var _ = gti.AddFunc(&gti.Func{
 	Name: "goki.dev/gsm/cmd.MakeIOSFramework",
 	Doc:  "MakeIOSFramework makes a .framework file for iOS from\na .dylib file, using the given config information.",
@@ -112,9 +306,51 @@ var _ = gti.AddFunc(&gti.Func{
 	}),
 })
 
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.Diff",
+	Doc:  "Diff prints the uncommitted changes (including untracked files) in all of the Git\nrepositories in the current directory, except for those ignored by the workspace\nmanifest and those not selected by the selector flags, as one combined diff with\nthe paths of the files prefixed with the directories of their repositories. The\ndiff is a [PatchBundle], so it can be written to a file and replayed onto another\nworkspace with [Apply].",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.Apply",
+	Doc:  "Apply replays the patch bundle written by [Diff] in the given file (or standard\ninput if it is -) onto the Git repositories in the current directory, applying the\npatch of each repository to the repository in the same directory, except for those\nnot selected by the selector flags. It first checks that all of the patches apply\ncleanly, and it does not change any repositories if any of them do not. It warns\nabout repositories that are not at the commit that their patch was made from. It\nprints a summary of the results for each repository, in which the repositories\nthat were patched are marked as changed.",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
 var _ = gti.AddFunc(&gti.Func{
 	Name: "goki.dev/gsm/cmd.Pull",
-	Doc:  "Pull concurrently pulls all of the Git repositories in the current directory.",
+	Doc:  "Pull concurrently pulls all of the Git repositories in the current directory,\nexcept for those ignored by the workspace manifest and those not selected by the\nselector flags. It warns about repositories whose origin differs from the remote\nURL specified in the manifest. Pulls that fail because of transient network\nproblems are retried. It prints a summary of the results for each repository.",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.Push",
+	Doc:  "Push concurrently pushes each of the Git repositories in the current directory\nwhose current branch has commits that have not been pushed to its upstream,\nexcept for those ignored by the workspace manifest and those not selected by\nthe selector flags. Repositories whose branch has no upstream are skipped, and\nthose whose branch has diverged from its upstream are not pushed and fail, since\nthey need to be handled manually. Pushes that fail because of transient network\nproblems are retried. It prints a summary of the results for each repository,\nin which the pushed repositories are marked as changed, along with the\ncategories of the changes that each repository had, like [Changed].",
 	Directives: gti.Directives{
 		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
 	},
@@ -128,8 +364,23 @@ var _ = gti.AddFunc(&gti.Func{
 
 var _ = gti.AddFunc(&gti.Func{
 	Name: "goki.dev/gsm/cmd.Release",
-	Doc:  "Release releases all of the Goki Go repositories in the current folder with goki.dev\nvanity import URLs (those without vanity import URLs should be released separately),\nrecursively updating each one and all of its dependencies (if the update flag is\non, which it is by default), but stopping after a couple of iterations due to\npseudo-import cycles at the module level.",
+	Doc:  "Release releases all of the Goki Git repositories in the current folder containing Go\nmodules with vanity import URLs (those without vanity import URLs should be\nreleased separately), in topological order of their [DependencyGraph], recursively\nupdating all of the modules in each one and all of its dependencies (if the update flag\nis on, which it is by default). Repositories that (indirectly) import each other are\nreleased together and then pinned to the new versions of each other and released\nagain if needed. Repositories marked as SkipRelease in the workspace manifest are\nnot released, and only the repositories selected by the selector flags (and the\nother repositories in their import cycles) are released. The version bump of each\nrelease is determined by [DecideBump]. If the dry run flag is on, it only prints\nthe [ReleasePlan]. Otherwise, it prints a summary of the results for each repository,\nin which released repositories are marked as changed. If it is interrupted, it\nfinishes releasing the current import cycle or repository and then stops, marking\nthe repositories left unreleased as incomplete.",
+	Directives: gti.Directives{
+		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
+	},
+	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"c", &gti.Field{Name: "c", Type: "*goki.dev/gsm/cmd.Config", LocalType: "*Config", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+	Returns: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
+		{"error", &gti.Field{Name: "error", Type: "error", LocalType: "error", Doc: "", Directives: gti.Directives{}, Tag: ""}},
+	}),
+})
+
+var _ = gti.AddFunc(&gti.Func{
+	Name: "goki.dev/gsm/cmd.PrintStatus",
+	Doc:  "PrintStatus concurrently gets the detailed status of all of the Git repositories\nin the current directory, except for those ignored by the workspace manifest and\nthose not selected by the selector flags, and prints it in the configured output\nformat. In the text format, it prints a table of the current branch (or the current\ncommit if HEAD is detached), upstream, ahead and behind counts, staged, unstaged,\nand untracked file counts, stash entries, latest tag, and commits since the latest\ntag of each repository. In the json and ndjson formats, it prints a\n[RepositoryStatus] for each repository.",
 	Directives: gti.Directives{
+		&gti.Directive{Tool: "grease", Directive: "cmd", Args: []string{"-name", "status"}},
 		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
 	},
 	Args: ordmap.Make([]ordmap.KeyVal[string, *gti.Field]{
@@ -142,7 +393,7 @@ var _ = gti.AddFunc(&gti.Func{
 
 var _ = gti.AddFunc(&gti.Func{
 	Name: "goki.dev/gsm/cmd.NewVanity",
-	Doc:  "NewVanity makes a new vanity import URL page for the config\nrepository name. It should only be called in the root directory\nof the goki.github.io repository. It commits and pushes the page.",
+	Doc:  "NewVanity makes a new vanity import URL page for the config\nrepository name, using the configured vanity import path prefix\nand Git host. It should only be called in the root directory of\nthe vanity import site repository (eg: goki.github.io). It commits\nand pushes the page.",
 	Directives: gti.Directives{
 		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
 	},
@@ -156,7 +407,7 @@ var _ = gti.AddFunc(&gti.Func{
 
 var _ = gti.AddFunc(&gti.Func{
 	Name: "goki.dev/gsm/cmd.Work",
-	Doc:  "Work adds all of the Go modules in the current directory to the go.work\nfile in the current directory.",
+	Doc:  "Work adds all of the Go modules in the current directory to the go.work\nfile in the current directory, except for those skipped by the workspace manifest\nand those in repositories not selected by the selector flags.",
 	Directives: gti.Directives{
 		&gti.Directive{Tool: "gti", Directive: "add", Args: []string{}},
 	},
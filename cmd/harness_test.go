@@ -0,0 +1,446 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/zip"
+)
+
+// This file contains a harness for end-to-end tests of gsm commands. It builds
+// a temporary workspace of local Git repositories with bare "remote" counterparts
+// and interdependent Go modules under a fake vanity import path prefix, which
+// are published to a local GOPROXY-style directory, so that commands can be run
+// without any network access. The goki tool used by [Release] is replaced by
+// the test binary itself (see [TestMain] and [fakeGoki]).
+
+// testVanity is the fake vanity import path prefix of test workspaces.
+const testVanity = "example.test"
+
+// fakeGokiEnv is the environment variable that makes
+// the test binary act as the goki tool.
+const fakeGokiEnv = "GSM_TEST_FAKE_GOKI"
+
+func TestMain(m *testing.M) {
+	if url := os.Getenv(fakeToolsEnv); url != "" && len(os.Args) > 1 {
+		err := runFakeTool(url, os.Args[1], os.Args[2:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, os.Args[1]+":", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if os.Getenv(fakeGokiEnv) != "" {
+		err := fakeGoki(os.Args[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "goki:", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testRepository specifies a repository in a [testWorkspace].
+type testRepository struct {
+
+	// Name is the name of the repository, which is also
+	// the last element of the path of its Go module
+	Name string
+
+	// Deps are the names of the repositories in the workspace
+	// that the module of the repository imports; they must
+	// be specified before the repository
+	Deps []string
+}
+
+// testWorkspace is a temporary workspace for end-to-end tests.
+type testWorkspace struct {
+	t *testing.T
+
+	// Dir is the workspace directory, in which commands are run
+	Dir string
+
+	// Remotes is the directory containing the bare remote repositories
+	Remotes string
+
+	// Proxy is the GOPROXY-style directory that modules are published to
+	Proxy string
+}
+
+// newTestWorkspace returns a new [testWorkspace] with the given repositories,
+// each of which has one commit containing a Go module, released as v0.1.0 and
+// published to the proxy. The repositories only exist as remotes; they are
+// listed with their remotes in the workspace manifest, so they can be cloned
+// with [Clone]. It sets the environment of the test so that Git and Go only
+// use the workspace, and it skips the test if that is not possible.
+func newTestWorkspace(t *testing.T, reps ...testRepository) *testWorkspace {
+	if testing.Short() {
+		t.Skip("skipping end-to-end test in short mode")
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("skipping end-to-end test on windows, since the fake goki tool is a shell script")
+	}
+	for _, tool := range []string{"git", "go"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("skipping end-to-end test because %s is not available: %v", tool, err)
+		}
+	}
+	root := t.TempDir()
+	w := &testWorkspace{
+		t:       t,
+		Dir:     filepath.Join(root, "workspace"),
+		Remotes: filepath.Join(root, "remotes"),
+		Proxy:   filepath.Join(root, "proxy"),
+	}
+	bin := filepath.Join(root, "bin")
+	for _, dir := range []string{w.Dir, w.Remotes, w.Proxy, bin} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := fmt.Sprintf("#!/bin/sh\n%s=1 exec '%s' \"$@\"\n", fakeGokiEnv, exe)
+	if err := os.WriteFile(filepath.Join(bin, "goki"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	gitConfig := filepath.Join(root, "gitconfig")
+	if err := os.WriteFile(gitConfig, []byte("[init]\n\tdefaultBranch = main\n[user]\n\tname = gsm\n\temail = gsm@example.test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"PATH":                bin + string(os.PathListSeparator) + os.Getenv("PATH"),
+		"HOME":                root,
+		"GIT_CONFIG_GLOBAL":   gitConfig,
+		"GIT_CONFIG_NOSYSTEM": "1",
+		"GIT_TERMINAL_PROMPT": "0",
+		"GOPROXY":             "file://" + filepath.ToSlash(w.Proxy),
+		"GOSUMDB":             "off",
+		"GONOSUMDB":           "",
+		"GOPRIVATE":           "",
+		"GONOPROXY":           "",
+		"GOFLAGS":             "-modcacherw",
+		"GOMODCACHE":          filepath.Join(root, "modcache"),
+		"GOTOOLCHAIN":         "local",
+		"GOWORK":              "",
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+
+	chdir(t, w.Dir)
+
+	manifest := &strings.Builder{}
+	for _, rep := range reps {
+		w.addRepository(rep)
+		fmt.Fprintf(manifest, "[[Repositories]]\n  Name = %q\n  Remote = %q\n\n", rep.Name, w.Remote(rep.Name))
+	}
+	if err := os.WriteFile(filepath.Join(w.Dir, "gsm.toml"), []byte(manifest.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// Remote returns the path of the bare remote repository with the given name.
+func (w *testWorkspace) Remote(name string) string {
+	return filepath.Join(w.Remotes, name+".git")
+}
+
+// addRepository adds the given repository to the workspace
+// as a remote, and releases and publishes its initial version.
+func (w *testWorkspace) addRepository(rep testRepository) {
+	w.t.Helper()
+	w.git(w.Remotes, "init", "--bare", "-q", rep.Name+".git")
+	dir := w.t.TempDir()
+	w.git(dir, "clone", "-q", w.Remote(rep.Name), ".")
+
+	src := &strings.Builder{}
+	fmt.Fprintf(src, "package %s\n\nimport (\n", rep.Name)
+	for _, dep := range rep.Deps {
+		fmt.Fprintf(src, "\t%q\n", path.Join(testVanity, dep))
+	}
+	fmt.Fprintf(src, ")\n\n// Name returns the name of the package and the packages it imports.\nfunc Name() string {\n\treturn %q", rep.Name)
+	for _, dep := range rep.Deps {
+		fmt.Fprintf(src, " + \" \" + %s.Name()", dep)
+	}
+	src.WriteString("\n}\n")
+	writeFiles(w.t, dir, map[string]string{rep.Name + ".go": src.String()})
+
+	mod := &strings.Builder{}
+	fmt.Fprintf(mod, "module %s\n\ngo 1.21\n", path.Join(testVanity, rep.Name))
+	for _, dep := range rep.Deps {
+		fmt.Fprintf(mod, "\nrequire %s v0.1.0\n", path.Join(testVanity, dep))
+	}
+	writeFiles(w.t, dir, map[string]string{"go.mod": mod.String()})
+	w.run(dir, "go", "mod", "tidy")
+
+	w.git(dir, "add", "-A")
+	w.git(dir, "commit", "-q", "-m", "initial commit")
+	w.git(dir, "tag", "-a", "v0.1.0", "-m", "v0.1.0")
+	w.git(dir, "push", "-q", "origin", "main", "v0.1.0")
+	if err := publishModule(w.Proxy, dir, "v0.1.0"); err != nil {
+		w.t.Fatal(err)
+	}
+}
+
+// CommitRemote commits the given files (keyed by slash-separated
+// path) with the given message to the remote repository with the
+// given name, as though someone else had pushed them.
+func (w *testWorkspace) CommitRemote(name, message string, files map[string]string) {
+	w.t.Helper()
+	dir := w.t.TempDir()
+	w.git(dir, "clone", "-q", w.Remote(name), ".")
+	writeFiles(w.t, dir, files)
+	w.git(dir, "add", "-A")
+	w.git(dir, "commit", "-q", "-m", message)
+	w.git(dir, "push", "-q")
+}
+
+// git runs git with the given arguments in the given
+// directory and returns its trimmed standard output.
+func (w *testWorkspace) git(dir string, args ...string) string {
+	w.t.Helper()
+	return w.run(dir, "git", args...)
+}
+
+// run runs the given command in the given directory and returns its
+// trimmed standard output. It fails the test if the command fails.
+func (w *testWorkspace) run(dir string, cmd string, args ...string) string {
+	w.t.Helper()
+	c := exec.Command(cmd, args...)
+	c.Dir = dir
+	stderr := &bytes.Buffer{}
+	c.Stderr = stderr
+	out, err := c.Output()
+	if err != nil {
+		w.t.Fatalf("%s %s: %v\n%s", cmd, strings.Join(args, " "), err, stderr)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Config returns the config that commands should be run with in the
+// workspace, which is like the default config except that it uses the
+// workspace manifest as the repository source, writes JSON output, and
+// does not retry failures.
+func (w *testWorkspace) Config() *Config {
+	return &Config{
+		Manifest:   "gsm.toml",
+		Vanity:     testVanity,
+		Host:       "github.com",
+		Org:        "goki",
+		Protocol:   "https",
+		Source:     "manifest",
+		Jobs:       4,
+		Timeout:    "5m",
+		RetryDelay: "1s",
+		Format:     "json",
+		Output:     "block",
+		Update:     true,
+	}
+}
+
+// runGsm runs the given command with the given config, failing the test if
+// it fails for reasons other than the results of the repositories, and returns
+// the results it printed in the json format and the error it returned.
+func runGsm(t *testing.T, cmd func(c *Config) error, c *Config) (*ResultsJSON, error) {
+	t.Helper()
+	out, err := captureStdout(t, func() error { return cmd(c) })
+	if err != nil && !errors.As(err, new(*ResultsError)) {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	rs := &ResultsJSON{}
+	if jerr := json.Unmarshal(out, rs); jerr != nil {
+		t.Fatalf("error parsing results: %v\n%s", jerr, out)
+	}
+	return rs, err
+}
+
+// chdir changes the current directory to the given
+// directory until the end of the given test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// writeFiles writes the given files, keyed by slash-separated
+// path, to the given directory, making their directories.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for fpath, content := range files {
+		fpath = filepath.Join(dir, filepath.FromSlash(fpath))
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fpath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// captureStdout calls the given function, returning what
+// it wrote to standard output along with its error.
+func captureStdout(t *testing.T, fun func() error) ([]byte, error) {
+	t.Helper()
+	f, err := os.CreateTemp(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stdout := os.Stdout
+	os.Stdout = f
+	err = fun()
+	os.Stdout = stdout
+	out, rerr := os.ReadFile(f.Name())
+	if rerr != nil {
+		t.Fatal(rerr)
+	}
+	return out, err
+}
+
+// statuses returns the status of each repository in the given results.
+func statuses(rs *ResultsJSON) map[string]Status {
+	res := map[string]Status{}
+	for _, r := range rs.Results {
+		res[r.Repository.Name] = r.Status
+	}
+	return res
+}
+
+// fakeGoki implements the subset of the goki tool that [ReleaseRepository]
+// uses, running in a Git repository containing a Go module. It stores the
+// version set by set-version in a VERSION file, and releasing commits all
+// changes, tags the version, pushes the commit and the tag, and publishes the
+// version to the proxy directory specified by GOPROXY.
+func fakeGoki(args []string) error {
+	if len(args) == 0 {
+		return errors.New("missing command")
+	}
+	switch args[0] {
+	case "get-version":
+		tag, err := exec.Command("git", "describe", "--abbrev=0").Output()
+		if err != nil {
+			return fmt.Errorf("error getting version: %w", err)
+		}
+		fmt.Print(string(tag))
+		return nil
+	case "set-version":
+		if len(args) != 2 {
+			return errors.New("usage: goki set-version <version>")
+		}
+		return os.WriteFile("VERSION", []byte(args[1]+"\n"), 0644)
+	case "version-release":
+		tag, _ := exec.Command("git", "describe", "--abbrev=0").Output()
+		err := os.WriteFile("VERSION", []byte(NextVersion(strings.TrimSpace(string(tag)), "patch")+"\n"), 0644)
+		if err != nil {
+			return err
+		}
+		return fakeGokiRelease()
+	case "release":
+		return fakeGokiRelease()
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// fakeGokiRelease releases the version in the VERSION file for [fakeGoki].
+func fakeGokiRelease() error {
+	b, err := os.ReadFile("VERSION")
+	if err != nil {
+		return err
+	}
+	v := strings.TrimSpace(string(b))
+	git := func(args ...string) error {
+		out, err := exec.Command("git", args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("git %s: %w\n%s", strings.Join(args, " "), err, out)
+		}
+		return nil
+	}
+	err = git("add", "-A")
+	if err == nil {
+		err = git("commit", "-q", "-m", "updated version to "+v)
+	}
+	if err == nil {
+		// we tag with ExecGit so that the end-to-end tests cover it
+		err = ExecGit{}.Tag(context.Background(), ".", v)
+	}
+	if err == nil {
+		err = git("push", "-q", "origin", "HEAD", v)
+	}
+	if err != nil {
+		return err
+	}
+	return publishModule(strings.TrimPrefix(os.Getenv("GOPROXY"), "file://"), ".", v)
+}
+
+// publishModule publishes the given version of the Go module at the root
+// of the Git repository in the given directory to the given GOPROXY-style
+// directory. The version must be a tag in the repository.
+func publishModule(proxy, dir, version string) error {
+	b, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return err
+	}
+	modPath := modfile.ModulePath(b)
+	epath, err := module.EscapePath(modPath)
+	if err != nil {
+		return err
+	}
+	vdir := filepath.Join(filepath.FromSlash(proxy), filepath.FromSlash(epath), "@v")
+	err = os.MkdirAll(vdir, 0755)
+	if err != nil {
+		return err
+	}
+	zf, err := os.Create(filepath.Join(vdir, version+".zip"))
+	if err != nil {
+		return err
+	}
+	defer zf.Close()
+	err = zip.CreateFromVCS(zf, module.Version{Path: modPath, Version: version}, dir, version, "")
+	if err != nil {
+		return fmt.Errorf("error making module zip: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(vdir, version+".mod"), b, 0644)
+	if err != nil {
+		return err
+	}
+	info := fmt.Sprintf(`{"Version":%q,"Time":%q}`, version, time.Now().UTC().Format(time.RFC3339))
+	err = os.WriteFile(filepath.Join(vdir, version+".info"), []byte(info), 0644)
+	if err != nil {
+		return err
+	}
+	lf, err := os.OpenFile(filepath.Join(vdir, "list"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer lf.Close()
+	_, err = fmt.Fprintln(lf, version)
+	return err
+}
@@ -15,6 +15,11 @@ import (
 // directory containing all of the goki repositories (set up with gsm clone),
 // and with a go.work file contianing all of those repositories (set up with gsm work).
 func InstallTools(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
 	paths := []string{
 		"goki",
 		"gsm",
@@ -22,7 +27,7 @@ func InstallTools(c *Config) error { //gti:add
 		filepath.Join("enums", "cmd", "enumgen"),
 	}
 	for _, path := range paths {
-		err := xe.Major().SetDir(path).Run("go", "install")
+		err := Run(ctx, xe.Major().SetDir(path), "go", "install")
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,61 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// List prints all of the Goki Git repositories containing Go modules in the
+// current directory, except for those ignored by the workspace manifest and
+// those not selected by the selector flags, in the configured output format.
+// In the text format, it prints a table of the names, directories, and modules
+// of the repositories; in the json and ndjson formats, it prints all of the
+// fields of the repositories.
+func List(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	all, err := GetLocalRepositories(ctx, c)
+	if err != nil {
+		return fmt.Errorf("error parsing packages: %w", err)
+	}
+	reps := []*Repository{}
+	for _, rep := range all {
+		if !m.Ignored(rep.Name) {
+			reps = append(reps, rep)
+		}
+	}
+	sel, err := NewSelection(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	reps, err = SelectRepositories(ctx, sel, reps)
+	if err != nil {
+		return err
+	}
+	if IsJSON(c.Format) {
+		return WriteJSON(os.Stdout, c.Format, reps, reps)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tDIR\tMODULES")
+	for _, rep := range reps {
+		mods := make([]string, len(rep.Modules))
+		for i, mod := range rep.Modules {
+			mods[i] = mod.Path
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", rep.Name, rep.Dir, strings.Join(mods, " "))
+	}
+	return tw.Flush()
+}
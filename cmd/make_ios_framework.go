@@ -15,12 +15,17 @@ import (
 // MakeIOSFramework makes a .framework file for iOS from
 // a .dylib file, using the given config information.
 func MakeIOSFramework(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
 	// based on https://stackoverflow.com/a/57795040
-	err := xe.Run("install_name_tool", "-id", "@executable_path/"+c.IOSFramework.Framework+".framework/"+c.IOSFramework.Framework, c.IOSFramework.Dylib)
+	err = Run(ctx, xe.Major(), "install_name_tool", "-id", "@executable_path/"+c.IOSFramework.Framework+".framework/"+c.IOSFramework.Framework, c.IOSFramework.Dylib)
 	if err != nil {
 		return err
 	}
-	err = xe.Run("lipo", "-create", c.IOSFramework.Dylib, "-output", c.IOSFramework.Framework)
+	err = Run(ctx, xe.Major(), "lipo", "-create", c.IOSFramework.Dylib, "-output", c.IOSFramework.Framework)
 	if err != nil {
 		return err
 	}
@@ -28,7 +33,7 @@ func MakeIOSFramework(c *Config) error { //gti:add
 	if err != nil {
 		return err
 	}
-	err = xe.Run("mv", c.IOSFramework.Framework, c.IOSFramework.Framework+".framework")
+	err = Run(ctx, xe.Major(), "mv", c.IOSFramework.Framework, c.IOSFramework.Framework+".framework")
 	if err != nil {
 		return err
 	}
@@ -41,11 +46,11 @@ func MakeIOSFramework(c *Config) error { //gti:add
 	if err != nil {
 		return err
 	}
-	err = xe.Run("codesign", "--force", "--deep", "--verbose=2", "--sign", c.IOSFramework.Developer, c.IOSFramework.Framework+".framework")
+	err = Run(ctx, xe.Major(), "codesign", "--force", "--deep", "--verbose=2", "--sign", c.IOSFramework.Developer, c.IOSFramework.Framework+".framework")
 	if err != nil {
 		return err
 	}
-	return xe.Run("codesign", "-vvvv", c.IOSFramework.Framework+".framework")
+	return Run(ctx, xe.Major(), "codesign", "-vvvv", c.IOSFramework.Framework+".framework")
 }
 
 var plistTmpl = template.Must(template.New("plist").Parse(
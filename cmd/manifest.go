@@ -0,0 +1,171 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"goki.dev/grows/tomls"
+)
+
+// Manifest is a declarative description of a workspace of Goki
+// repositories, typically loaded from a gsm.toml file at the root of
+// the workspace. It determines which repositories are skipped by which
+// commands, so that work-in-progress repositories can be handled without
+// changing gsm itself. An example manifest file is:
+//
+//	WorkExclude = ["internal"]
+//
+//	[[Repositories]]
+//	  Name = "gipy"
+//	  Group = "wip"
+//	  SkipRelease = true
+//	  SkipWork = true
+//
+//	[[Repositories]]
+//	  Name = "mytool"
+//	  Remote = "https://github.com/me/mytool"
+//
+// The Group of a repository can be used to select repositories with the
+// group selector flag. Also, because gsm.toml is the default config file,
+// it can contain config settings like Exclude in addition to the manifest.
+type Manifest struct {
+
+	// Repositories contains the settings for specific repositories
+	// in the workspace. Repositories that are not listed use the
+	// default settings.
+	Repositories []*ManifestRepository
+
+	// WorkExclude contains path patterns (in the format of [path.Match])
+	// of directories that should not be added to the go.work file by
+	// [Work]. A module directory is excluded if any element of its path
+	// matches any of the patterns.
+	WorkExclude []string
+}
+
+// ManifestRepository contains the manifest settings for one repository.
+type ManifestRepository struct {
+
+	// Name is the name of the repository, which is also the name of
+	// its directory in the workspace
+	Name string
+
+	// Group is an arbitrary group label for the repository
+	// (eg: "core", "tools", or "wip")
+	Group string
+
+	// Remote is the expected remote URL of the repository. If it is
+	// set, it is used when cloning the repository instead of the default
+	// URL, and pulling warns when the origin of the repository differs.
+	Remote string
+
+	// Ignore is whether to ignore the repository entirely
+	// in every command
+	Ignore bool
+
+	// SkipRelease is whether to skip the repository when releasing
+	SkipRelease bool
+
+	// SkipWork is whether to skip the modules of the repository
+	// when adding modules to the go.work file
+	SkipWork bool
+}
+
+// DefaultManifest returns the manifest that is used when there is no
+// manifest file in the workspace. It contains the settings for the
+// work-in-progress repositories of the main Goki workspace.
+func DefaultManifest() *Manifest {
+	m := &Manifest{
+		WorkExclude: []string{"internal"},
+	}
+	for _, nm := range []string{"gipy", "goki.github.io"} {
+		m.Repositories = append(m.Repositories, &ManifestRepository{Name: nm, SkipRelease: true, SkipWork: true})
+	}
+	for _, nm := range []string{"grid", "gopix", "rqlite", "gorqlite"} {
+		m.Repositories = append(m.Repositories, &ManifestRepository{Name: nm, SkipRelease: true})
+	}
+	m.Repositories = append(m.Repositories, &ManifestRepository{Name: "android-go", SkipWork: true})
+	return m
+}
+
+// LoadManifest loads the manifest from the given file. If the file
+// does not exist, it returns [DefaultManifest].
+func LoadManifest(file string) (*Manifest, error) {
+	m := &Manifest{}
+	err := tomls.Open(m, file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return DefaultManifest(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading manifest file %q: %w", file, err)
+	}
+	return m, nil
+}
+
+// Repository returns the manifest settings for the repository
+// with the given name, or nil if it is not listed in the manifest.
+func (m *Manifest) Repository(name string) *ManifestRepository {
+	for _, mr := range m.Repositories {
+		if mr.Name == name {
+			return mr
+		}
+	}
+	return nil
+}
+
+// Ignored returns whether the repository with the given
+// name should be ignored by every command.
+func (m *Manifest) Ignored(name string) bool {
+	mr := m.Repository(name)
+	return mr != nil && mr.Ignore
+}
+
+// SkipRelease returns whether the repository with the
+// given name should be skipped when releasing.
+func (m *Manifest) SkipRelease(name string) bool {
+	mr := m.Repository(name)
+	return mr != nil && (mr.Ignore || mr.SkipRelease)
+}
+
+// SkipWork returns whether the module in the given directory
+// (relative to the root of the workspace) should be skipped when
+// adding modules to the go.work file.
+func (m *Manifest) SkipWork(dir string) bool {
+	dir = filepath.ToSlash(dir)
+	mr := m.Repository(path.Base(findRepositoryDir(dir)))
+	if mr != nil && (mr.Ignore || mr.SkipWork) {
+		return true
+	}
+	for _, elem := range strings.Split(dir, "/") {
+		for _, pat := range m.WorkExclude {
+			if ok, _ := path.Match(pat, elem); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Remote returns the expected remote URL of the repository with
+// the given name, or "" if there is no expected remote URL.
+func (m *Manifest) Remote(name string) string {
+	mr := m.Repository(name)
+	if mr == nil {
+		return ""
+	}
+	return mr.Remote
+}
+
+// repositoryOfDir returns the name of the repository containing the given
+// slash-separated directory, relative to the root of the workspace.
+func repositoryOfDir(dir string) string {
+	before, _, _ := strings.Cut(dir, "/")
+	return before
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"goki.dev/xe"
+)
+
+// outputMu protects the terminal from output of
+// different repositories being written at the same time.
+var outputMu sync.Mutex
+
+// outputKey is the context key for [RepositoryOutput]
+type outputKey struct{}
+
+// RepositoryOutput captures the output of the external commands run on one
+// repository with [Run] and [Output], so that the output of commands run on
+// different repositories concurrently does not interleave. It is created by
+// [RunRepositories] and passed to commands through the context.
+type RepositoryOutput struct {
+
+	// Stdout receives the output that would otherwise go to standard output,
+	// with each line prefixed with the name of the repository
+	Stdout io.Writer
+
+	// Stderr receives the output that would otherwise go to standard error,
+	// with each line prefixed with the name of the repository
+	Stderr io.Writer
+
+	// Log is the log file of the repository, which receives all of the commands
+	// run on the repository and their output regardless of verbosity, or nil
+	// if there is no log file
+	Log *os.File
+
+	// block contains the buffers that Stdout and Stderr write to in the block
+	// output mode, which are written to the terminal when the repository is done
+	block [2]*bytes.Buffer
+
+	// prefixers are the prefix writers of Stdout and Stderr
+	prefixers [2]*prefixWriter
+}
+
+// NewRepositoryOutput returns a new [RepositoryOutput] for the given repository
+// in the config Output mode. If the given log directory is not "", it creates a log
+// file for the repository in it.
+func NewRepositoryOutput(c *Config, logDir string, rep *Repository) (*RepositoryOutput, error) {
+	name := rep.Dir
+	if name == "" || name == "." {
+		name = rep.Name
+	}
+	ro := &RepositoryOutput{}
+	prefix := "[" + name + "] "
+	if c.Output == "stream" {
+		ro.prefixers = [2]*prefixWriter{{w: os.Stdout, prefix: prefix, lock: true}, {w: os.Stderr, prefix: prefix, lock: true}}
+	} else {
+		ro.block = [2]*bytes.Buffer{{}, {}}
+		ro.prefixers = [2]*prefixWriter{{w: ro.block[0], prefix: prefix}, {w: ro.block[1], prefix: prefix}}
+	}
+	ro.Stdout, ro.Stderr = ro.prefixers[0], ro.prefixers[1]
+	if logDir == "" {
+		return ro, nil
+	}
+	var err error
+	ro.Log, err = os.Create(filepath.Join(logDir, strings.ReplaceAll(name, "/", "_")+".log"))
+	if err != nil {
+		return nil, fmt.Errorf("error creating log file: %w", err)
+	}
+	return ro, nil
+}
+
+// Close writes the given result to the log file and closes it, and, in the
+// block output mode, writes all of the output of the repository to the terminal.
+func (ro *RepositoryOutput) Close(r *Result) error {
+	for _, pw := range ro.prefixers {
+		pw.Flush()
+	}
+	if ro.block[0] != nil {
+		outputMu.Lock()
+		os.Stdout.Write(ro.block[0].Bytes())
+		os.Stderr.Write(ro.block[1].Bytes())
+		outputMu.Unlock()
+	}
+	if ro.Log == nil {
+		return nil
+	}
+	fmt.Fprintf(ro.Log, "\n%s after %v\n", r.Status, r.Duration.Round(time.Millisecond))
+	if r.Err != nil {
+		fmt.Fprintf(ro.Log, "error: %v\n", r.Err)
+	}
+	return ro.Log.Close()
+}
+
+// logCommand writes the given command, its output, and its error
+// to the log file, if there is one.
+func (ro *RepositoryOutput) logCommand(cmd string, stdout, stderr []byte, err error) {
+	if ro.Log == nil {
+		return
+	}
+	fmt.Fprintf(ro.Log, "$ %s\n", cmd)
+	ro.Log.Write(stdout)
+	ro.Log.Write(stderr)
+	if err != nil {
+		fmt.Fprintf(ro.Log, "error: %v\n", err)
+	}
+}
+
+// withOutput returns a copy of the given xe config with its output redirected to the
+// [RepositoryOutput] in the given context, along with it. If there is no repository
+// output in the context, it returns the given config and nil.
+func withOutput(ctx context.Context, xc *xe.Config) (*xe.Config, *RepositoryOutput) {
+	ro, _ := ctx.Value(outputKey{}).(*RepositoryOutput)
+	if ro == nil {
+		return xc, nil
+	}
+	res := *xc
+	if res.Stdout != nil {
+		res.Stdout = ro.Stdout
+	}
+	if res.Commands != nil {
+		res.Commands = ro.Stdout
+	}
+	if res.Stderr != nil {
+		res.Stderr = ro.Stderr
+	}
+	if res.Errors != nil {
+		res.Errors = ro.Stderr
+	}
+	return &res, ro
+}
+
+// RunLogDir returns the directory that the log files of the current run of a command
+// should be written to, which is a new directory in the config LogDir named by the
+// current time. It returns "" if the config LogDir is "".
+func (c *Config) RunLogDir() (string, error) {
+	if c.LogDir == "" {
+		return "", nil
+	}
+	dir := filepath.Join(c.LogDir, time.Now().Format("20060102-150405"))
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", fmt.Errorf("error making log directory: %w", err)
+	}
+	return dir, nil
+}
+
+// prefixWriter is an [io.Writer] that writes each line
+// written to it to an underlying writer with a prefix.
+type prefixWriter struct {
+
+	// w is the underlying writer
+	w io.Writer
+
+	// prefix is the prefix of each line
+	prefix string
+
+	// lock is whether to lock [outputMu] while writing
+	lock bool
+
+	// partial contains the last line written if it is incomplete
+	partial []byte
+}
+
+func (pw *prefixWriter) Write(b []byte) (int, error) {
+	n := len(b)
+	pw.partial = append(pw.partial, b...)
+	i := bytes.LastIndexByte(pw.partial, '\n')
+	if i < 0 {
+		return n, nil
+	}
+	lines := pw.partial[:i+1]
+	buf := &bytes.Buffer{}
+	for len(lines) > 0 {
+		line, rest, _ := bytes.Cut(lines, []byte{'\n'})
+		buf.WriteString(pw.prefix)
+		buf.Write(line)
+		buf.WriteByte('\n')
+		lines = rest
+	}
+	pw.partial = append([]byte{}, pw.partial[i+1:]...)
+	return n, pw.write(buf.Bytes())
+}
+
+// Flush writes the last line written if it is incomplete.
+func (pw *prefixWriter) Flush() error {
+	if len(pw.partial) == 0 {
+		return nil
+	}
+	line := append([]byte(pw.prefix), pw.partial...)
+	pw.partial = nil
+	return pw.write(append(line, '\n'))
+}
+
+// write writes the given bytes to the underlying writer.
+func (pw *prefixWriter) write(b []byte) error {
+	if pw.lock {
+		outputMu.Lock()
+		defer outputMu.Unlock()
+	}
+	_, err := pw.w.Write(b)
+	return err
+}
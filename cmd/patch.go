@@ -0,0 +1,295 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// patchBundleHeader is the first line of a [PatchBundle].
+const patchBundleHeader = "# gsm patch bundle"
+
+// patchRepositoryPrefix is the prefix of the line that starts
+// the patch of each repository in a [PatchBundle].
+const patchRepositoryPrefix = "# repository: "
+
+// PatchBundle is a set of patches of the uncommitted changes in multiple
+// repositories of a workspace, which can be replayed onto another workspace
+// with [Apply]. It is written as one combined patch in the Git diff format
+// in which the paths of the files are prefixed with the directories of their
+// repositories, with a comment line before the patch of each repository that
+// records its directory and current commit. Since tools like git apply ignore
+// such lines, the combined patch can also be applied directly in the
+// workspace directory.
+type PatchBundle struct {
+
+	// Patches are the patches of the repositories
+	Patches []*RepositoryPatch
+}
+
+// RepositoryPatch is the patch of one repository in a [PatchBundle].
+type RepositoryPatch struct {
+
+	// Dir is the slash-separated directory of the repository
+	// relative to the workspace directory
+	Dir string
+
+	// Commit is the hash of the commit that the patch is
+	// relative to, or "" if the repository has no commits
+	Commit string
+
+	// Patch is the patch in the Git diff format, with the paths
+	// of the files prefixed with the directory of the repository
+	Patch string
+}
+
+// Diff prints the uncommitted changes (including untracked files) in all of the Git
+// repositories in the current directory, except for those ignored by the workspace
+// manifest and those not selected by the selector flags, as one combined diff with
+// the paths of the files prefixed with the directories of their repositories. The
+// diff is a [PatchBundle], so it can be written to a file and replayed onto another
+// workspace with [Apply].
+func Diff(c *Config) error { //gti:add
+	if c.Diff.File == "" {
+		// the diff needs to be the only thing written to stdout
+		quietStdout()
+	}
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	reps, err := GitRepositories(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	b, err := GetPatchBundle(ctx, c, reps)
+	if err != nil {
+		return err
+	}
+	if c.Diff.File == "" {
+		return b.Write(os.Stdout)
+	}
+	f, err := os.Create(c.Diff.File)
+	if err != nil {
+		return fmt.Errorf("error creating patch bundle file: %w", err)
+	}
+	err = b.Write(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error writing patch bundle file: %w", err)
+	}
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("error writing patch bundle file: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote patches of %d repositories to %s\n", len(b.Patches), c.Diff.File)
+	return nil
+}
+
+// GetPatchBundle concurrently returns the [PatchBundle] of the uncommitted changes
+// in the given repositories, which only contains those that have changes.
+func GetPatchBundle(ctx context.Context, c *Config, reps []*Repository) (*PatchBundle, error) {
+	patches, err := Map(ctx, c, reps, func(rep *Repository) (*RepositoryPatch, error) {
+		git := GitFrom(ctx)
+		dir := filepath.FromSlash(rep.Dir)
+		st, err := git.Status(ctx, dir)
+		if err != nil {
+			return nil, fmt.Errorf("error getting status of repository %q: %w", rep.Name, err)
+		}
+		prefix := ""
+		if rep.Dir != "." {
+			prefix = rep.Dir + "/"
+		}
+		patch, err := git.Patch(ctx, dir, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("error getting patch of repository %q: %w", rep.Name, err)
+		}
+		return &RepositoryPatch{Dir: rep.Dir, Commit: st.Commit, Patch: patch}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	b := &PatchBundle{}
+	for _, p := range patches {
+		if p.Patch != "" {
+			b.Patches = append(b.Patches, p)
+		}
+	}
+	return b, nil
+}
+
+// Write writes the bundle to the given writer
+// in the format described in [PatchBundle].
+func (b *PatchBundle) Write(w io.Writer) error {
+	_, err := fmt.Fprintln(w, patchBundleHeader)
+	if err != nil {
+		return err
+	}
+	for _, p := range b.Patches {
+		_, err := fmt.Fprintf(w, "%s%s %s\n%s", patchRepositoryPrefix, p.Dir, p.Commit, p.Patch)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadPatchBundle reads a [PatchBundle] written
+// by [PatchBundle.Write] from the given reader.
+func ReadPatchBundle(r io.Reader) (*PatchBundle, error) {
+	br := bufio.NewReader(r)
+	b := &PatchBundle{}
+	var cur *RepositoryPatch
+	patch := &strings.Builder{}
+	first := true
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		if line == "" && err != nil {
+			break
+		}
+		switch {
+		case first:
+			if strings.TrimSuffix(line, "\n") != patchBundleHeader {
+				return nil, errors.New("not a gsm patch bundle (missing header)")
+			}
+			first = false
+		case strings.HasPrefix(line, patchRepositoryPrefix):
+			if cur != nil {
+				cur.Patch = patch.String()
+				patch.Reset()
+			}
+			dir, commit, _ := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, patchRepositoryPrefix)), " ")
+			if dir == "" || path.IsAbs(dir) || dir != path.Clean(dir) || strings.HasPrefix(dir, "../") {
+				return nil, fmt.Errorf("invalid repository directory %q in patch bundle", dir)
+			}
+			cur = &RepositoryPatch{Dir: dir, Commit: commit}
+			b.Patches = append(b.Patches, cur)
+		case cur == nil:
+			return nil, fmt.Errorf("invalid line before the first repository in patch bundle: %q", line)
+		default:
+			patch.WriteString(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	if first {
+		return nil, errors.New("not a gsm patch bundle (empty)")
+	}
+	if cur != nil {
+		cur.Patch = patch.String()
+	}
+	return b, nil
+}
+
+// Apply replays the patch bundle written by [Diff] in the given file (or standard
+// input if it is -) onto the Git repositories in the current directory, applying the
+// patch of each repository to the repository in the same directory, except for those
+// not selected by the selector flags. It first checks that all of the patches apply
+// cleanly, and it does not change any repositories if any of them do not. It warns
+// about repositories that are not at the commit that their patch was made from. It
+// prints a summary of the results for each repository, in which the repositories
+// that were patched are marked as changed.
+func Apply(c *Config) error { //gti:add
+	var r io.Reader = os.Stdin
+	if c.Apply.File != "-" {
+		f, err := os.Open(c.Apply.File)
+		if err != nil {
+			return fmt.Errorf("error opening patch bundle: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	b, err := ReadPatchBundle(r)
+	if err != nil {
+		return fmt.Errorf("error reading patch bundle: %w", err)
+	}
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	sel, err := NewSelection(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	patches := map[*Repository]*RepositoryPatch{}
+	reps := []*Repository{}
+	for _, p := range b.Patches {
+		rep := c.LocalRepository(p.Dir)
+		patches[rep] = p
+		reps = append(reps, rep)
+	}
+	reps, err = SelectRepositories(ctx, sel, reps)
+	if err != nil {
+		return err
+	}
+	_, err = Map(ctx, c, reps, func(rep *Repository) (struct{}, error) {
+		return struct{}{}, checkPatch(ctx, rep, patches[rep])
+	})
+	if err != nil {
+		return fmt.Errorf("not applying any patches: %w", err)
+	}
+	rs := RunRepositories(ctx, c, reps, func(ctx context.Context, rep *Repository) (Status, error) {
+		p := patches[rep]
+		err := GitFrom(ctx).Apply(ctx, filepath.FromSlash(rep.Dir), p.Patch, patchStrip(p.Dir), false)
+		if err != nil {
+			return StatusFailed, fmt.Errorf("error applying patch: %w", err)
+		}
+		return StatusChanged, nil
+	})
+	return rs.Finish(os.Stdout, c.Format)
+}
+
+// checkPatch returns an error if the given patch can not be
+// applied cleanly to the given repository. It warns if the
+// repository is not at the commit that the patch is relative to.
+func checkPatch(ctx context.Context, rep *Repository, p *RepositoryPatch) error {
+	git := GitFrom(ctx)
+	dir := filepath.FromSlash(rep.Dir)
+	st, err := git.Status(ctx, dir)
+	if err != nil {
+		return fmt.Errorf("error getting status of repository %q: %w", rep.Name, err)
+	}
+	if st.Commit != p.Commit {
+		slog.Warn("repository is not at the commit that the patch was made from", "repository", rep.Name, "commit", st.Commit, "patch", p.Commit)
+	}
+	err = git.Apply(ctx, dir, p.Patch, patchStrip(p.Dir), true)
+	if err != nil {
+		return fmt.Errorf("patch does not apply to repository %q: %w", rep.Name, err)
+	}
+	return nil
+}
+
+// patchStrip returns the number of leading path elements to remove
+// from the paths in the patch of the repository in the given directory,
+// which are the a/ or b/ prefix and the elements of the directory.
+func patchStrip(dir string) int {
+	if dir == "." {
+		return 1
+	}
+	return 1 + len(strings.Split(dir, "/"))
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"golang.org/x/mod/semver"
+)
+
+// ReleasePlan is a prediction of what a release cycle will do,
+// computed by [PlanRelease] without changing any repositories.
+type ReleasePlan struct {
+
+	// Changed are the repositories that have changed since their last release
+	Changed []*Repository
+
+	// Steps are the repositories that will be released, in release order
+	Steps []*ReleaseStep
+}
+
+// ReleaseStep is the plan for releasing one repository in a [ReleasePlan].
+type ReleaseStep struct {
+
+	// Repository is the repository that will be released
+	Repository *Repository
+
+	// Reason is why the repository will be released
+	Reason string
+
+	// Cycle contains the names of the other repositories in the
+	// import cycle of the repository, if it is in one
+	Cycle []string
+
+	// Bumps are the Goki imports that will be updated
+	// to new versions, in the form module@version
+	Bumps []string
+
+	// Bump is the version bump (patch, minor, or major) of the release
+	Bump string
+
+	// BumpReason is why the version bump was chosen
+	BumpReason string
+
+	// NextVersion is the predicted version of the release
+	NextVersion string
+}
+
+// PlanRelease returns the [ReleasePlan] for the given strongly connected
+// components of the given dependency graph in release order, as returned by
+// [DependencyGraph.Components]. It determines whether each repository has
+// changed using Git, but it does not change any repositories. The plan does
+// not account for changes caused by updating non-Goki dependencies.
+func PlanRelease(ctx context.Context, c *Config, g *DependencyGraph, comps [][]*Repository) (*ReleasePlan, error) {
+	p := &ReleasePlan{}
+	steps := map[*Repository]*ReleaseStep{}
+	for _, comp := range comps {
+		if err := context.Cause(ctx); err != nil {
+			return nil, err
+		}
+		for _, rep := range comp {
+			err := UpdateChanged(ctx, rep)
+			if err != nil {
+				return nil, err
+			}
+			if rep.Changed {
+				p.Changed = append(p.Changed, rep)
+			}
+		}
+		// the repositories in the component that will be released
+		released := []*ReleaseStep{}
+		for _, rep := range comp {
+			step := &ReleaseStep{Repository: rep}
+			switch {
+			case rep.Version == "":
+				step.Reason = "initial release"
+			case rep.Changed:
+				step.Reason = "changed"
+			case c.Update && slices.ContainsFunc(g.Dependencies[rep], func(dep *Repository) bool { return steps[dep] != nil }):
+				step.Reason = "dependencies released"
+			}
+			released = append(released, step)
+		}
+		// every repository in a cycle indirectly depends on every other one, so if we are
+		// updating and any of them are released, they all are; otherwise, only the ones
+		// with reasons are released
+		anyReleased := slices.ContainsFunc(released, func(step *ReleaseStep) bool { return step.Reason != "" })
+		released = slices.DeleteFunc(released, func(step *ReleaseStep) bool {
+			if step.Reason == "" && c.Update && anyReleased {
+				step.Reason = "dependencies released"
+			}
+			return step.Reason == ""
+		})
+		for _, step := range released {
+			bump, reason, err := DecideBump(ctx, c, step.Repository)
+			if err != nil {
+				return nil, err
+			}
+			step.Bump, step.BumpReason = bump, reason
+			step.NextVersion = NextVersion(step.Repository.Version, bump)
+			steps[step.Repository] = step
+		}
+		for _, step := range released {
+			if len(comp) > 1 {
+				for _, rep := range comp {
+					if rep != step.Repository {
+						step.Cycle = append(step.Cycle, rep.Name)
+					}
+				}
+			}
+			if c.Update {
+				step.Bumps = planBumps(g, step.Repository, steps)
+			}
+			p.Steps = append(p.Steps, step)
+		}
+	}
+	return p, nil
+}
+
+// planBumps returns the Goki imports of the given repository that will
+// be updated to new versions based on the given planned release steps,
+// in the form module@version.
+func planBumps(g *DependencyGraph, rep *Repository, steps map[*Repository]*ReleaseStep) []string {
+	res := []string{}
+	for _, mod := range rep.Modules {
+		for _, imp := range mod.GokiImports {
+			dep := g.Repository(imp)
+			if dep == nil || dep == rep || steps[dep] == nil {
+				continue
+			}
+			bump := imp + "@" + steps[dep].NextVersion
+			if !slices.Contains(res, bump) {
+				res = append(res, bump)
+			}
+		}
+	}
+	return res
+}
+
+// NextVersion returns the version that results from applying the given
+// bump ("major", "minor", or "patch") to the given semantic version.
+// If the given version is "", it returns v0.1.0, the initial version.
+func NextVersion(version string, bump string) string {
+	if version == "" || !semver.IsValid(version) {
+		return "v0.1.0"
+	}
+	var major, minor, patch int
+	fmt.Sscanf(semver.Canonical(version), "v%d.%d.%d", &major, &minor, &patch)
+	switch bump {
+	case "major":
+		return fmt.Sprintf("v%d.0.0", major+1)
+	case "minor":
+		return fmt.Sprintf("v%d.%d.0", major, minor+1)
+	}
+	return fmt.Sprintf("v%d.%d.%d", major, minor, patch+1)
+}
+
+// Write writes the plan to the given writer in the given output format,
+// which is either text (see [ReleasePlan.Print]), json (the plan as a
+// JSON object), or ndjson (each of the steps as a JSON object on its own line).
+func (p *ReleasePlan) Write(w io.Writer, format string) error {
+	if format == "text" || format == "" {
+		return p.Print(w)
+	}
+	return WriteJSON(w, format, p, p.Steps)
+}
+
+// Print prints the plan to the given writer in a human-readable format.
+func (p *ReleasePlan) Print(w io.Writer) error {
+	fmt.Fprintf(w, "Changed repositories: %s\n\n", strings.Join(RepositoryNames(p.Changed), ", "))
+	if len(p.Steps) == 0 {
+		_, err := fmt.Fprintln(w, "Nothing to release")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ORDER\tREPOSITORY\tREASON\tVERSION\tBUMP\tNEXT\tBUMPS")
+	for i, step := range p.Steps {
+		reason := step.Reason
+		if len(step.Cycle) > 0 {
+			reason += " (cycle with " + strings.Join(step.Cycle, ", ") + ")"
+		}
+		version := step.Repository.Version
+		if version == "" {
+			version = "-"
+		}
+		bump := step.Bump + " (" + step.BumpReason + ")"
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", i+1, step.Repository.Name, reason, version, bump, step.NextVersion, strings.Join(step.Bumps, " "))
+	}
+	return tw.Flush()
+}
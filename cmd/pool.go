@@ -0,0 +1,67 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// MaxJobs returns the maximum number of items that should be
+// processed concurrently, based on the config Jobs field.
+func (c *Config) MaxJobs() int {
+	if c.Jobs <= 0 {
+		return runtime.NumCPU()
+	}
+	return c.Jobs
+}
+
+// Map concurrently calls the given function on each of the given items, running
+// at most [Config.MaxJobs] calls at once. It returns the results and the joined
+// errors of the calls in the same order as the items, regardless of the order in
+// which the calls finish, so that the output of commands is deterministic. Once
+// the given context is done, it stops starting new calls, and the items that were
+// not started result in errors wrapping the cause of the context being done.
+func Map[T, R any](ctx context.Context, c *Config, items []T, fun func(item T) (R, error)) ([]R, error) {
+	res := make([]R, len(items))
+	errs := make([]error, len(items))
+	sem := make(chan struct{}, c.MaxJobs())
+	wg := sync.WaitGroup{}
+	wg.Add(len(items))
+	for i, item := range items {
+		i, item := i, item
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		// we check again in case both cases of the select were ready
+		if ctx.Err() != nil {
+			errs[i] = fmt.Errorf("not started: %w", context.Cause(ctx))
+			wg.Done()
+			continue
+		}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			// each call only writes to its own index, so we don't need a mutex
+			res[i], errs[i] = fun(item)
+		}()
+	}
+	wg.Wait()
+	return res, errors.Join(errs...)
+}
+
+// ForEach is like [Map], but for functions that only return an error.
+func ForEach[T any](ctx context.Context, c *Config, items []T, fun func(item T) error) error {
+	_, err := Map(ctx, c, items, func(item T) (struct{}, error) {
+		return struct{}{}, fun(item)
+	})
+	return err
+}
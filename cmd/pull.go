@@ -5,35 +5,53 @@
 package cmd
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"sync"
-
-	"goki.dev/xe"
 )
 
-// Pull concurrently pulls all of the Git repositories in the current directory.
+// Pull concurrently pulls all of the Git repositories in the current directory,
+// except for those ignored by the workspace manifest and those not selected by the
+// selector flags. It warns about repositories whose origin differs from the remote
+// URL specified in the manifest. Pulls that fail because of transient network
+// problems are retried. It prints a summary of the results for each repository.
 func Pull(c *Config) error { //gti:add
-	wg := sync.WaitGroup{}
-	errs := []error{}
-	fs.WalkDir(os.DirFS("."), ".", func(path string, d fs.DirEntry, err error) error {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if d.Name() != ".git" {
-				return
-			}
-			dir := filepath.Dir(path)
-			err := xe.Major().SetDir(dir).Run("git", "pull")
-			if err != nil {
-				errs = append(errs, fmt.Errorf("error pulling %q: %w", dir, err))
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	reps, err := GitRepositories(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	rs := RunRepositories(ctx, c, reps, func(ctx context.Context, rep *Repository) (Status, error) {
+		dir := filepath.FromSlash(rep.Dir)
+		if remote := m.Remote(rep.Name); remote != "" {
+			origin, err := GitFrom(ctx).RemoteURL(ctx, dir, "origin")
+			if err == nil && origin != remote {
+				slog.Warn("origin of repository differs from manifest remote", "repository", rep.Dir, "origin", origin, "remote", remote)
 			}
-		}()
-		return nil
+		}
+		// we compare the commits before and after pulling to determine whether anything changed
+		before, _ := GitFrom(ctx).Head(ctx, dir)
+		err := Retry(ctx, c, "pull "+rep.Dir, func() error {
+			return GitFrom(ctx).Pull(ctx, dir)
+		})
+		if err != nil {
+			return StatusFailed, fmt.Errorf("error pulling %q: %w", dir, err)
+		}
+		after, _ := GitFrom(ctx).Head(ctx, dir)
+		if before != after {
+			return StatusChanged, nil
+		}
+		return StatusOK, nil
 	})
-	wg.Wait()
-	return errors.Join(errs...)
+	return rs.Finish(os.Stdout, c.Format)
 }
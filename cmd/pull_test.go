@@ -0,0 +1,48 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPullFakeGit(t *testing.T) {
+	w := newFakeWorkspace(t)
+	files := map[string]string{"README.md": "# test\n"}
+	for _, name := range []string{"ahead", "behind", "clean", "diverged"} {
+		w.AddRepository(name, files)
+	}
+	w.Git.Repositories["ahead"].AddCommit("fix: local", map[string]string{"a.txt": "a\n"})
+	w.Git.Remotes[w.Remote("behind")].AddCommit("fix: remote", map[string]string{"b.txt": "b\n"})
+	w.Git.Remotes[w.Remote("behind")].Tags["v0.1.1"] = 1
+	w.Git.Repositories["diverged"].AddCommit("fix: local", map[string]string{"a.txt": "a\n"})
+	w.Git.Remotes[w.Remote("diverged")].AddCommit("fix: remote", map[string]string{"b.txt": "b\n"})
+
+	rs, err := runGsm(t, Pull, w.Config())
+	if err == nil {
+		t.Fatal("expected an error from pulling a diverged repository")
+	}
+	checkStatuses(t, rs, map[string]Status{"ahead": StatusOK, "behind": StatusChanged, "clean": StatusOK, "diverged": StatusFailed})
+	for _, r := range rs.Results {
+		if r.Repository.Name == "diverged" && !strings.Contains(r.Error, "not possible to fast-forward") {
+			t.Errorf("expected diverged repository to fail to fast-forward, but got error %q", r.Error)
+		}
+	}
+
+	behind, rbehind := w.Git.Repositories["behind"], w.Git.Remotes[w.Remote("behind")]
+	if !slices.Equal(behind.Commits, rbehind.Commits) {
+		t.Errorf("expected pulled repository to have %d commits, but it has %d", len(rbehind.Commits), len(behind.Commits))
+	}
+	if _, ok := behind.Tags["v0.1.1"]; !ok {
+		t.Errorf("expected pulled repository to have the new tag, but it has %v", behind.Tags)
+	}
+	for _, name := range []string{"ahead", "behind", "clean", "diverged"} {
+		if !slices.Contains(w.Git.Calls, "pull "+name) {
+			t.Errorf("expected %s to be pulled, but the calls were %v", name, w.Git.Calls)
+		}
+	}
+}
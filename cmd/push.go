@@ -0,0 +1,66 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// Push concurrently pushes each of the Git repositories in the current directory
+// whose current branch has commits that have not been pushed to its upstream,
+// except for those ignored by the workspace manifest and those not selected by
+// the selector flags. Repositories whose branch has no upstream are skipped, and
+// those whose branch has diverged from its upstream are not pushed and fail, since
+// they need to be handled manually. Pushes that fail because of transient network
+// problems are retried. It prints a summary of the results for each repository,
+// in which the pushed repositories are marked as changed, along with the
+// categories of the changes that each repository had, like [Changed].
+func Push(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	reps, err := GitRepositories(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	rs := RunRepositories(ctx, c, reps, func(ctx context.Context, rep *Repository) (Status, error) {
+		dir := filepath.FromSlash(rep.Dir)
+		st, err := GitFrom(ctx).Status(ctx, dir)
+		if err != nil {
+			return StatusFailed, fmt.Errorf("error getting status: %w", err)
+		}
+		rep.Changes = st.Changes()
+		rep.Changed = len(rep.Changes) > 0
+		switch {
+		case st.Branch == "" || st.Commit == "": // detached or no commits
+			return StatusOK, nil
+		case st.Upstream == "":
+			slog.Warn("not pushing branch with no upstream; push it with git push -u", "repository", rep.Name, "branch", st.Branch)
+			return StatusSkipped, nil
+		case st.Ahead > 0 && st.Behind > 0:
+			return StatusFailed, fmt.Errorf("branch %q has diverged from %q (pull it first)", st.Branch, st.Upstream)
+		case st.Ahead == 0:
+			return StatusOK, nil
+		}
+		err = Retry(ctx, c, "push "+rep.Dir, func() error {
+			return GitFrom(ctx).Push(ctx, dir)
+		})
+		if err != nil {
+			return StatusFailed, fmt.Errorf("error pushing %q: %w", dir, err)
+		}
+		return StatusChanged, nil
+	})
+	return rs.Finish(os.Stdout, c.Format)
+}
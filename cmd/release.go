@@ -5,220 +5,309 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"slices"
 	"strings"
+	"time"
 
 	"goki.dev/grog"
 	"goki.dev/xe"
+	"golang.org/x/mod/semver"
 )
 
-// Release releases all of the Goki Go repositories in the current folder with goki.dev
-// vanity import URLs (those without vanity import URLs should be released separately),
-// recursively updating each one and all of its dependencies (if the update flag is
-// on, which it is by default), but stopping after a couple of iterations due to
-// pseudo-import cycles at the module level.
+// Release releases all of the Goki Git repositories in the current folder containing Go
+// modules with vanity import URLs (those without vanity import URLs should be
+// released separately), in topological order of their [DependencyGraph], recursively
+// updating all of the modules in each one and all of its dependencies (if the update flag
+// is on, which it is by default). Repositories that (indirectly) import each other are
+// released together and then pinned to the new versions of each other and released
+// again if needed. Repositories marked as SkipRelease in the workspace manifest are
+// not released, and only the repositories selected by the selector flags (and the
+// other repositories in their import cycles) are released. The version bump of each
+// release is determined by [DecideBump]. If the dry run flag is on, it only prints
+// the [ReleasePlan]. Otherwise, it prints a summary of the results for each repository,
+// in which released repositories are marked as changed. If it is interrupted, it
+// finishes releasing the current import cycle or repository and then stops, marking
+// the repositories left unreleased as incomplete.
 func Release(c *Config) error { //gti:add
-	reps, err := GetLocalRepositories()
+	ctx, cancel, err := c.Context()
 	if err != nil {
-		return fmt.Errorf("error parsing packages: %w", err)
+		return err
 	}
-
-	// if we don't need to update, we can just simply release each changed repository
-	if !c.Update {
-		for _, rep := range reps {
-			if skipRepo(rep) {
-				continue
+	defer cancel()
+	g, comps, err := ReleaseComponents(ctx, c)
+	if err != nil {
+		return err
+	}
+	if c.DryRun {
+		p, err := PlanRelease(ctx, c, g, comps)
+		if err != nil {
+			return err
+		}
+		return p.Write(os.Stdout, c.Format)
+	}
+	rs := Results{}
+	for i, comp := range comps {
+		start := time.Now()
+		err := context.Cause(ctx)
+		if err != nil {
+			err = fmt.Errorf("not started: %w", err)
+		} else {
+			err = ReleaseComponent(ctx, c, g, comp)
+		}
+		status := StatusFailed
+		if errors.Is(err, ErrInterrupted) {
+			status = StatusIncomplete
+		}
+		for _, rep := range comp {
+			r := &Result{Repository: rep, Status: StatusOK, Duration: time.Since(start)}
+			switch {
+			case rep.Released:
+				r.Status = StatusChanged
+			case err != nil:
+				r.Status, r.Err = status, err
 			}
-
-			tag, err := xe.Minor().SetDir(rep.Name).Output("git", "describe", "--abbrev=0")
-			if err != nil {
-				return fmt.Errorf("error getting latest tag for repository %q: %w", rep.Name, err)
+			rs = append(rs, r)
+		}
+		if err != nil {
+			// we can not release the remaining repositories, since
+			// they may depend on the ones that were not released
+			for _, comp := range comps[i+1:] {
+				for _, rep := range comp {
+					rs = append(rs, &Result{Repository: rep, Status: StatusIncomplete, Err: errors.New("not started: release stopped")})
+				}
 			}
-			rep.Version = tag
-			rep.Changed, err = RepositoryHasChanged(rep, tag)
+			break
+		}
+	}
+	return rs.Finish(os.Stdout, c.Format)
+}
+
+// ReleaseComponents returns the dependency graph of the local repositories
+// that can be released (those not marked as SkipRelease in the workspace
+// manifest) and its strongly connected components that contain any repositories
+// selected by the selector flags, in release order, as described in [Release].
+func ReleaseComponents(ctx context.Context, c *Config) (*DependencyGraph, [][]*Repository, error) {
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return nil, nil, err
+	}
+	all, err := GetLocalRepositories(ctx, c)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing packages: %w", err)
+	}
+	reps := []*Repository{}
+	for _, rep := range all {
+		if !m.SkipRelease(rep.Name) {
+			reps = append(reps, rep)
+		}
+	}
+	g, err := NewDependencyGraph(reps)
+	if err != nil {
+		return nil, nil, fmt.Errorf("can not release because the dependency graph is incomplete: %w", err)
+	}
+	sel, err := NewSelection(ctx, c, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	selected, err := SelectRepositories(ctx, sel, reps)
+	if err != nil {
+		return nil, nil, err
+	}
+	// we release import cycles as a whole, so we keep
+	// every component with any selected repositories
+	comps := slices.DeleteFunc(g.Components(), func(comp []*Repository) bool {
+		return !slices.ContainsFunc(comp, func(rep *Repository) bool { return slices.Contains(selected, rep) })
+	})
+	return g, comps, nil
+}
+
+// ReleaseComponent releases all of the changed repositories in the given
+// strongly connected component of the given dependency graph, as described
+// in [Release]. All of the components that the component depends on must
+// have already been released.
+func ReleaseComponent(ctx context.Context, c *Config, g *DependencyGraph, comp []*Repository) error {
+	for _, rep := range comp {
+		if c.Update {
+			err := PinDependencies(ctx, g, rep)
 			if err != nil {
 				return err
 			}
-			if !rep.Changed {
-				continue
-			}
-
-			err = ReleaseRepository(rep)
+			err = UpdateRepository(ctx, rep)
 			if err != nil {
 				return err
 			}
 		}
-		return nil
-	}
-
-	repsm := map[string]*Repository{} // map of repositories
-	for _, rep := range reps {
-		if skipRepo(rep) {
-			continue
+		err := UpdateChanged(ctx, rep)
+		if err != nil {
+			return err
 		}
-		repsm[rep.VanityURL] = rep
-
-		tag, err := xe.Minor().SetDir(rep.Name).Output("git", "describe", "--abbrev=0")
+	}
+	if len(comp) > 1 {
+		slog.Info("releasing import cycle together", "repositories", RepositoryNames(comp))
+	}
+	release := func(rep *Repository) error {
+		bump, reason, err := DecideBump(ctx, c, rep)
 		if err != nil {
-			// if we have an error getting the latest version, we probably
-			// have no released version, so we need to do an initial release
-			slog.Warn("no latest version found for repository; doing initial release", "repository", rep.Name)
-			err := ReleaseRepository(rep)
-			if err != nil {
-				return err
-			}
-			tag = rep.Version
+			return err
 		}
-		rep.Version = tag
-		rep.Changed, err = RepositoryHasChanged(rep, tag)
+		slog.Info("releasing repository", "repository", rep.Name, "bump", bump, "reason", reason)
+		err = ReleaseRepository(ctx, rep, bump)
 		if err != nil {
 			return err
 		}
-
-		// if we have Goki imports, we need to update them first, so we skip
-		if len(rep.GokiImports) > 0 {
+		rep.Released = true
+		return nil
+	}
+	for _, rep := range comp {
+		if !rep.Changed {
 			continue
 		}
-
-		// don't use sum db to avoid problems (see https://github.com/golang/go/issues/42809)
-		xc := xe.Major().SetDir(rep.Name).SetEnv("GONOSUMDB", "*")
-
-		err = xc.Run("go", "get", "-u", "./...")
+		err := release(rep)
+		if err != nil {
+			return err
+		}
+	}
+	if len(comp) == 1 || !c.Update {
+		return nil
+	}
+	// in a cycle, each repository was released with the old versions of the
+	// others, so we need to pin the new versions and release again if that changed anything
+	for _, rep := range comp {
+		err := PinDependencies(ctx, g, rep)
 		if err != nil {
-			return fmt.Errorf("error updating deps for repository %q: %w", rep.Name, err)
+			return err
 		}
-		err = xc.Run("go", "mod", "tidy")
+		err = UpdateRepository(ctx, rep)
 		if err != nil {
-			return fmt.Errorf("error tidying mod for repository %q: %w", rep.Name, err)
+			return err
 		}
-
-		// check again if we are changed after updating deps and mod
-		rep.Changed, err = RepositoryHasChanged(rep, tag)
+		err = UpdateChanged(ctx, rep)
 		if err != nil {
 			return err
 		}
-
-		if rep.Changed { // if we are changed and have no Goki imports, we can release right now
-			err := ReleaseRepository(rep)
-			if err != nil {
-				return err
-			}
-			rep.Released = true
+		if !rep.Changed {
+			continue
+		}
+		err = release(rep)
+		if err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// now that we have done a first pass to get the ones with no Goki imports,
-	// we check again based on the newly released ones, until we run out of
-	// repositories to release. we set a backup break point of 10.
-	for i := 0; i < 10; i++ {
-		needRelease := false // whether we still have something that needs to be released but can't be
-		for _, rep := range reps {
-			if skipRepo(rep) {
-				continue
-			}
-			if rep.Released { // if we are already released, we skip
-				continue
-			}
-			hasGokiImport := false // whether we still have changed but unreleased Goki imports
+// UpdateChanged sets the version of the given repository to its latest
+// Git version tag and sets whether it has changed since that version.
+// If it has no version tag, it has never been released, so it is
+// considered changed, which results in an initial release.
+func UpdateChanged(ctx context.Context, rep *Repository) error {
+	tag, err := GitFrom(ctx).Describe(ctx, rep.Dir)
+	if errors.Is(err, ErrNoTags) {
+		// if we have no tags, we have no released version,
+		// so we need to do an initial release
+		slog.Warn("no latest version found for repository; it needs an initial release", "repository", rep.Name)
+		rep.Version = ""
+		rep.Changed = true
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting latest version of repository %q: %w", rep.Name, err)
+	}
+	rep.Version = tag
+	rep.Changed, err = RepositoryHasChanged(ctx, rep, tag)
+	return err
+}
 
-			// don't use sum db to avoid problems (see https://github.com/golang/go/issues/42809)
-			xc := xe.Major().SetDir(rep.Name).SetEnv("GONOSUMDB", "*")
+// PinDependencies updates each of the modules of the given repository to
+// require the new versions of all of its Goki imports from other repositories
+// in the given dependency graph that have been released in the context of
+// this command.
+func PinDependencies(ctx context.Context, g *DependencyGraph, rep *Repository) error {
+	for _, mod := range rep.Modules {
+		// don't use sum db to avoid problems (see https://github.com/golang/go/issues/42809)
+		xc := xe.Major().SetDir(mod.Dir).SetEnv("GONOSUMDB", "*")
 
-			for _, imp := range rep.GokiImports {
-				impr := repsm[imp]
-				if impr == nil {
-					return fmt.Errorf("missing repository for import %q; you might need to run gsm clone", imp)
-				}
-				if !impr.Changed { // if the import hasn't been changed, we don't need to update it
-					continue
-				}
-				if !impr.Released { // if the import has changed but hasn't been released, we have to wait for them to release first
-					hasGokiImport = true
-					continue
-				}
-				// otherwise, we need to update to the latest release
-				err := xc.Run("go", "get", impr.VanityURL+"@"+impr.Version)
-				if err != nil {
-					return fmt.Errorf("error updating Goki import %q for repository %q: %w", impr.Name, rep.Name, err)
-				}
-			}
-			// we skip if we still have unreleased Goki imports,
-			// unless we are on the second pass and are one of the three
-			// special cyclically importing repositories
-			if hasGokiImport && !(i == 1 && (rep.Name == "enums" || rep.Name == "gti" || rep.Name == "grease")) {
-				needRelease = true
+		for _, imp := range mod.GokiImports {
+			if rep.Module(imp) != nil { // imports of our own modules are updated when we release
 				continue
 			}
-
-			// now we make sure we have the latest versions of everything
-			err := xc.Run("go", "get", "-u", "./...")
-			if err != nil {
-				return fmt.Errorf("error updating deps for repository %q: %w", rep.Name, err)
-			}
-			err = xc.Run("go", "mod", "tidy")
-			if err != nil {
-				return fmt.Errorf("error tidying mod for repository %q: %w", rep.Name, err)
-			}
-			tag, err := xe.Minor().SetDir(rep.Name).Output("git", "describe", "--abbrev=0")
-			if err != nil {
-				return fmt.Errorf("error getting latest tag for repository %q: %w", rep.Name, err)
-			}
-			rep.Version = tag
-
-			// we skip if we still haven't changed
-			rep.Changed, err = RepositoryHasChanged(rep, rep.Version)
-			if err != nil {
-				return err
-			}
-			if !rep.Changed {
+			dep := g.Repository(imp)
+			if dep == nil || !dep.Released { // if the import hasn't been released, we don't need to update it
 				continue
 			}
-
-			// otherwise, we can release
-			err = ReleaseRepository(rep)
+			err := Run(ctx, xc, "go", "get", imp+"@"+dep.Version)
 			if err != nil {
-				return err
+				return fmt.Errorf("error updating Goki import %q for module %q: %w", imp, mod.Path, err)
 			}
-			rep.Released = true
-		}
-		if !needRelease {
-			break
 		}
 	}
 	return nil
 }
 
-// skipRepo returns whether to skip the given repository.
-// TODO(kai): remove this TEMPORARY fix for some repos being a WIP
-func skipRepo(rep *Repository) bool {
-	skips := []string{"gipy", "grid", "gopix", "goki.github.io", "rqlite", "gorqlite"}
-	return slices.Contains(skips, rep.Name)
+// UpdateRepository updates the dependencies of and tidies
+// each of the modules of the given repository.
+func UpdateRepository(ctx context.Context, rep *Repository) error {
+	for _, mod := range rep.Modules {
+		// don't use sum db to avoid problems (see https://github.com/golang/go/issues/42809)
+		xc := xe.Major().SetDir(mod.Dir).SetEnv("GONOSUMDB", "*")
+
+		err := Run(ctx, xc, "go", "get", "-u", "./...")
+		if err != nil {
+			return fmt.Errorf("error updating deps for module %q: %w", mod.Path, err)
+		}
+		err = Run(ctx, xc, "go", "mod", "tidy")
+		if err != nil {
+			return fmt.Errorf("error tidying mod for module %q: %w", mod.Path, err)
+		}
+	}
+	return nil
 }
 
 // RepositoryHasChanged returns whether the given repository
 // has changed since the given Git version tag.
-func RepositoryHasChanged(rep *Repository, tag string) (bool, error) {
-	diff, err := xe.Minor().SetDir(rep.Name).Output("git", "diff", tag)
+func RepositoryHasChanged(ctx context.Context, rep *Repository, tag string) (bool, error) {
+	diff, err := GitFrom(ctx).Diff(ctx, rep.Dir, tag)
 	if err != nil {
 		return false, fmt.Errorf("error getting diff from latest tag %q for repository %q: %w", tag, rep.Name, err)
 	}
 	return diff != "", nil
 }
 
-// ReleaseRepository releases the given repository by calling
-// "goki update-version" and "goki release".
-func ReleaseRepository(rep *Repository) error {
-	xc := xe.Major().SetDir(rep.Name)
+// ReleaseRepository releases the given repository with the given version
+// bump ("patch", "minor", or "major"). Patch releases and initial releases
+// use "goki version-release"; other releases set the next version with
+// "goki set-version" and then call "goki release". Major releases of
+// repositories that are already at v1 or higher are not supported, since
+// they require changing the module paths (eg: adding a /v2 suffix).
+func ReleaseRepository(ctx context.Context, rep *Repository, bump string) error {
+	xc := xe.Major().SetDir(rep.Dir)
 
-	err := xc.Run("goki", "version-release")
-	if err != nil {
-		return fmt.Errorf("error getting updating version of repository %q: %w", rep.Name, err)
+	if bump == "patch" || rep.Version == "" {
+		err := Run(ctx, xc, "goki", "version-release")
+		if err != nil {
+			return fmt.Errorf("error getting updating version of repository %q: %w", rep.Name, err)
+		}
+	} else {
+		if bump == "major" && semver.Major(rep.Version) != "v0" {
+			return fmt.Errorf("cannot release a new major version of repository %q: its module paths must be changed manually (eg: with a /%s suffix)", rep.Name, semver.Major(NextVersion(rep.Version, bump)))
+		}
+		err := Run(ctx, xc, "goki", "set-version", NextVersion(rep.Version, bump))
+		if err != nil {
+			return fmt.Errorf("error setting version of repository %q: %w", rep.Name, err)
+		}
+		err = Run(ctx, xc, "goki", "release")
+		if err != nil {
+			return fmt.Errorf("error releasing repository %q: %w", rep.Name, err)
+		}
 	}
 	grog.PrintlnWarn(grog.SuccessColor("Released "), grog.CmdColor(rep.Name))
 
-	nv, err := xc.Output("goki", "get-version")
+	nv, err := Output(ctx, xc, "goki", "get-version")
 	if err != nil {
 		return fmt.Errorf("error getting new version of repository %q: %w", rep.Name, err)
 	}
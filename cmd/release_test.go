@@ -0,0 +1,265 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"golang.org/x/mod/modfile"
+)
+
+func TestReleaseFakeGit(t *testing.T) {
+	w := newFakeWorkspace(t)
+	w.AddRepository("api", map[string]string{
+		"go.mod": "module goki.dev/api\n\ngo 1.21\n",
+		"api.go": "package api\n\n// Old is deprecated.\nfunc Old() {}\n\n// New is new.\nfunc New() {}\n",
+	})
+	w.AddRepository("base", map[string]string{
+		"go.mod":  "module goki.dev/base\n\ngo 1.21\n",
+		"base.go": "package base\n\n// Name returns the name of the package.\nfunc Name() string { return \"base\" }\n",
+	})
+	w.AddRepository("fresh", map[string]string{
+		"go.mod": "module goki.dev/fresh\n\ngo 1.21\n",
+	})
+	w.AddRepository("mid", map[string]string{
+		"go.mod": "module goki.dev/mid\n\ngo 1.21\n\nrequire goki.dev/base v0.1.0\n",
+		"mid.go": "package mid\n\nimport \"goki.dev/base\"\n\n// Name returns the name of base.\nfunc Name() string { return base.Name() }\n",
+	})
+	w.AddRepository("other", map[string]string{
+		"go.mod": "module goki.dev/other\n\ngo 1.21\n",
+	})
+	// removing an exported function is a breaking change even without a breaking commit message
+	w.Commit("api", "fix: remove Old", map[string]string{"api.go": "package api\n\n// New is new.\nfunc New() {}\n"})
+	w.Commit("base", "feat: add Hello", map[string]string{
+		"base.go": "package base\n\n// Name returns the name of the package.\nfunc Name() string { return \"base\" }\n\n// Hello says hello.\nfunc Hello() string { return \"hello\" }\n",
+	})
+	// fresh has never been released
+	w.Git.Repositories["fresh"].Tags = nil
+
+	c := w.Config()
+	c.DryRun = true
+	out, err := captureStdout(t, func() error { return Release(c) })
+	if err != nil {
+		t.Fatalf("error planning release: %v\n%s", err, out)
+	}
+	p := &ReleasePlan{}
+	if err := json.Unmarshal(out, p); err != nil {
+		t.Fatalf("error parsing release plan: %v\n%s", err, out)
+	}
+
+	changed := RepositoryNames(p.Changed)
+	slices.Sort(changed)
+	if strings.Join(changed, " ") != "api base fresh" {
+		t.Errorf("expected api, base, and fresh to be changed, but got %v", changed)
+	}
+	want := map[string]struct{ reason, bump, version string }{
+		"api":   {"changed", "minor", "v0.2.0"},
+		"base":  {"changed", "minor", "v0.2.0"},
+		"fresh": {"initial release", "patch", "v0.1.0"},
+		"mid":   {"dependencies released", "patch", "v0.1.1"},
+	}
+	order := []string{}
+	for _, step := range p.Steps {
+		name := step.Repository.Name
+		order = append(order, name)
+		exp, ok := want[name]
+		if !ok {
+			t.Errorf("expected %s not to be released", name)
+			continue
+		}
+		if step.Reason != exp.reason || step.Bump != exp.bump || step.NextVersion != exp.version {
+			t.Errorf("expected %s to be released because of %q with a %s bump to %s, but got %q, %s, and %s (%s)",
+				name, exp.reason, exp.bump, exp.version, step.Reason, step.Bump, step.NextVersion, step.BumpReason)
+		}
+	}
+	if len(order) != len(want) {
+		t.Errorf("expected %d repositories to be released, but got %v", len(want), order)
+	}
+	if slices.Index(order, "base") > slices.Index(order, "mid") {
+		t.Errorf("expected base to be released before mid, but got %v", order)
+	}
+	for _, call := range w.Git.Calls {
+		if !strings.HasPrefix(call, "clone ") {
+			t.Errorf("expected planning not to change any repositories, but got %q", call)
+		}
+	}
+}
+
+// releaseTools returns fake go and goki tools for [Release] in the given workspace
+// (see [fakeWorkspace.SetTools]) that change its repositories in its [FakeGit]: go get
+// pins the given version in the go.mod file in the working tree, other go commands
+// do nothing, and goki commits, tags, and pushes releases. It records each invocation
+// in calls (eg: "mid: go get goki.dev/base@v0.2.0"). Releases fail in the
+// repositories in fail.
+func releaseTools(w *fakeWorkspace, calls *[]string, fail ...string) map[string]fakeTool {
+	ctx := w.Context()
+	versions := map[string]string{}
+	release := func(dir string) error {
+		if slices.Contains(fail, dir) {
+			return errors.New("error releasing")
+		}
+		v := versions[dir]
+		w.Git.mu.Lock()
+		fr := w.Git.Repositories[dir]
+		files := maps.Clone(fr.worktree())
+		files["VERSION"] = v + "\n"
+		fr.Files = files
+		w.Git.mu.Unlock()
+		err := w.Git.Commit(ctx, dir, "updated version to "+v)
+		if err != nil {
+			return err
+		}
+		err = w.Git.Tag(ctx, dir, v)
+		if err != nil {
+			return err
+		}
+		return w.Git.Push(ctx, dir)
+	}
+	return map[string]fakeTool{
+		"go": func(dir string, args []string) (string, error) {
+			*calls = append(*calls, dir+": go "+strings.Join(args, " "))
+			if len(args) != 2 || args[0] != "get" || !strings.Contains(args[1], "@") {
+				return "", nil
+			}
+			mod, version, _ := strings.Cut(args[1], "@")
+			w.Git.mu.Lock()
+			defer w.Git.mu.Unlock()
+			fr := w.Git.Repositories[dir]
+			files := maps.Clone(fr.worktree())
+			mf, err := modfile.Parse("go.mod", []byte(files["go.mod"]), nil)
+			if err != nil {
+				return "", err
+			}
+			err = mf.AddRequire(mod, version)
+			if err != nil {
+				return "", err
+			}
+			b, err := mf.Format()
+			if err != nil {
+				return "", err
+			}
+			files["go.mod"] = string(b)
+			fr.Files = files
+			return "", nil
+		},
+		"goki": func(dir string, args []string) (string, error) {
+			*calls = append(*calls, dir+": goki "+strings.Join(args, " "))
+			switch args[0] {
+			case "get-version":
+				return w.Git.Describe(ctx, dir)
+			case "set-version":
+				versions[dir] = args[1]
+				return "", nil
+			case "version-release":
+				tag, err := w.Git.Describe(ctx, dir)
+				if err != nil && !errors.Is(err, ErrNoTags) {
+					return "", err
+				}
+				versions[dir] = NextVersion(tag, "patch")
+				return "", release(dir)
+			case "release":
+				return "", release(dir)
+			}
+			return "", fmt.Errorf("unknown command %q", args[0])
+		},
+	}
+}
+
+// addReleaseRepositories adds the repositories used by the tests of [Release] to
+// the given workspace: base has a feature commit since v0.1.0, mid requires base,
+// fresh has never been released, and other has not changed since v0.1.0.
+func addReleaseRepositories(w *fakeWorkspace) {
+	w.AddRepository("base", map[string]string{
+		"go.mod":  "module goki.dev/base\n\ngo 1.21\n",
+		"base.go": "package base\n\n// Name returns the name of the package.\nfunc Name() string { return \"base\" }\n",
+	})
+	w.AddRepository("fresh", map[string]string{"go.mod": "module goki.dev/fresh\n\ngo 1.21\n"})
+	w.AddRepository("mid", map[string]string{"go.mod": "module goki.dev/mid\n\ngo 1.21\n\nrequire goki.dev/base v0.1.0\n"})
+	w.AddRepository("other", map[string]string{"go.mod": "module goki.dev/other\n\ngo 1.21\n"})
+	w.Commit("base", "feat: add Hello", map[string]string{
+		"hello.go": "package base\n\n// Hello says hello.\nfunc Hello() string { return \"hello\" }\n",
+	})
+	w.Git.Repositories["fresh"].Tags = nil
+}
+
+func TestReleaseRunFakeGit(t *testing.T) {
+	w := newFakeWorkspace(t)
+	addReleaseRepositories(w)
+	calls := []string{}
+	w.SetTools(releaseTools(w, &calls))
+
+	rs, err := runGsm(t, Release, w.Config())
+	if err != nil {
+		t.Fatalf("error releasing: %v\n%s", err, strings.Join(calls, "\n"))
+	}
+	checkStatuses(t, rs, map[string]Status{"base": StatusChanged, "fresh": StatusChanged, "mid": StatusChanged, "other": StatusOK})
+	for name, want := range map[string]string{"base": "v0.2.0", "fresh": "v0.1.0", "mid": "v0.1.1", "other": "v0.1.0"} {
+		got, err := w.Git.Describe(w.Context(), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("expected latest version of %s to be %s, but got %s", name, want, got)
+		}
+		fr, rfr := w.Git.Repositories[name], w.Git.Remotes[w.Remote(name)]
+		if !slices.Equal(fr.Commits, rfr.Commits) {
+			t.Errorf("expected %s to be pushed", name)
+		}
+	}
+	gomod, err := w.Git.Show(w.Context(), "mid", "v0.1.1", "go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(gomod), "goki.dev/base v0.2.0") {
+		t.Errorf("expected mid v0.1.1 to require base v0.2.0, but its go.mod is:\n%s", gomod)
+	}
+	for _, want := range []string{
+		"base: goki set-version v0.2.0",
+		"base: goki release",
+		"fresh: goki version-release",
+		"mid: go get goki.dev/base@v0.2.0",
+		"mid: go get -u ./...",
+		"mid: goki version-release",
+		"other: go mod tidy",
+	} {
+		if !slices.Contains(calls, want) {
+			t.Errorf("expected call %q, but got:\n%s", want, strings.Join(calls, "\n"))
+		}
+	}
+	for _, call := range calls {
+		if strings.HasPrefix(call, "other: goki") {
+			t.Errorf("expected other not to be released, but got call %q", call)
+		}
+	}
+}
+
+func TestReleaseFailFakeGit(t *testing.T) {
+	w := newFakeWorkspace(t)
+	addReleaseRepositories(w)
+	calls := []string{}
+	w.SetTools(releaseTools(w, &calls, "base"))
+
+	rs, err := runGsm(t, Release, w.Config())
+	if err == nil {
+		t.Fatal("expected an error from the failed release")
+	}
+	// base is released first, and no more repositories are
+	// started after a release fails, since they may depend on it
+	checkStatuses(t, rs, map[string]Status{"base": StatusFailed, "fresh": StatusIncomplete, "mid": StatusIncomplete, "other": StatusIncomplete})
+	for _, call := range calls {
+		if !strings.HasPrefix(call, "base: ") {
+			t.Errorf("expected only base to be started, but got call %q", call)
+		}
+	}
+	if tags := w.Git.Repositories["base"].Tags; len(tags) != 1 {
+		t.Errorf("expected base not to be tagged, but it has tags %v", tags)
+	}
+}
@@ -5,24 +5,26 @@
 package cmd
 
 import (
-	"errors"
+	"context"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strings"
-	"sync"
+	"time"
 
-	"github.com/iancoleman/strcase"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
 	"golang.org/x/net/html"
 	"golang.org/x/net/html/atom"
 )
 
-// Repository represents a Goki Go repository. Not all fields
-// are used by all use cases.
+// Repository represents a Goki Git repository, which contains
+// one or more Go modules. Not all fields are used by all use cases.
 type Repository struct {
 	// The actual GitHub name of the repository
 	Name string
@@ -30,79 +32,301 @@ type Repository struct {
 	Title string
 	// The URL of the GitHub repository (including https://)
 	RepositoryURL string
-	// The goki.dev vanity import URL of the repository (not including https://)
+	// The vanity import URL of the repository (not including https://),
+	// which is the module path of the root module of the repository
 	VanityURL string
-	// The Goki imports of the repository
+	// The directory of the repository on the local filesystem,
+	// relative to the current directory
+	Dir string
+	// The Go modules with vanity import URLs contained in the repository
+	Modules []*Module
+	// The Goki imports of the repository, which are the Goki imports
+	// of all of its modules, excluding its own modules
 	GokiImports []string
 	// Whether the repository has changed since the last release
 	Changed bool
+	// The categories of local changes of the repository
+	// that need to be updated in version control, as
+	// determined by the changed command
+	Changes []Change
 	// Whether the repository has been released in the context of this command
 	Released bool
 	// The version of the repository
 	Version string
 }
 
-// GetLocalRepositories concurrently gets all of the Goki Go
-// repositories with goki.dev vanity import URLs in the current
-// directory on the local filesystem.
-func GetLocalRepositories() ([]*Repository, error) {
-	wg := sync.WaitGroup{}
-	errs := []error{}
-	res := []*Repository{}
-	fs.WalkDir(os.DirFS("."), ".", func(dpath string, d fs.DirEntry, err error) error {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if d.Name() != "go.mod" {
-				return
-			}
-			dir := filepath.Dir(dpath)
-			b, err := os.ReadFile(dpath)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("error reading mod file for %q: %w", dir, err))
-				return
-			}
-			mod, err := modfile.Parse(dpath, b, nil)
-			if err != nil {
-				errs = append(errs, fmt.Errorf("error parsing mod file for %q: %w", dir, err))
-				return
-			}
-			// we only care about repositories with goki.dev vanity import URLs
-			if !strings.HasPrefix(mod.Module.Mod.Path, "goki.dev") {
-				return
-			}
-			nm := path.Base(path.Dir(dpath)) // can't use mod path because of major version suffixes; easier to just use this
-			rep := &Repository{
-				Name:          nm,
-				Title:         strcase.ToCamel(nm),
-				RepositoryURL: "https://github.com/goki/" + nm,
-				VanityURL:     mod.Module.Mod.Path,
-			}
-			for _, req := range mod.Require {
-				// we only care about dependencies with goki.dev vanity import URLs
-				if !strings.HasPrefix(req.Mod.Path, "goki.dev") {
+// Module represents a Go module contained in a [Repository].
+type Module struct {
+	// The module path of the module (eg: goki.dev/gi/v2)
+	Path string
+	// The directory of the module on the local filesystem,
+	// relative to the current directory
+	Dir string
+	// The Goki imports of the module
+	GokiImports []string
+	// The versions of the Goki imports of the module required
+	// in its go.mod file, keyed by module path
+	Requires map[string]string
+}
+
+// GetLocalRepositories concurrently gets all of the Goki Git
+// repositories containing Go modules with the configured vanity
+// import path prefix in the current directory on the local filesystem.
+func GetLocalRepositories(ctx context.Context, c *Config) ([]*Repository, error) {
+	files := []string{}
+	err := fs.WalkDir(os.DirFS("."), ".", func(dpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skipModuleDir(dpath, d) {
+			return fs.SkipDir
+		}
+		if d.Name() == "go.mod" {
+			files = append(files, dpath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding mod files: %w", err)
+	}
+	res, err := Map(ctx, c, files, func(dpath string) (*Module, error) {
+		return readModule(c, dpath)
+	})
+	mods := []*Module{}
+	for _, mod := range res {
+		if mod != nil {
+			mods = append(mods, mod)
+		}
+	}
+	return groupModules(c, mods), err
+}
+
+// GitRepositories returns all of the Git repositories in the current directory
+// on the local filesystem in lexical order of their directories, excluding those
+// ignored by the given workspace manifest and those not selected by the selector
+// fields of the given config. Unlike [GetLocalRepositories], it includes
+// repositories that do not contain Go modules, and it does not set the modules
+// of the repositories.
+func GitRepositories(ctx context.Context, c *Config, m *Manifest) ([]*Repository, error) {
+	reps := []*Repository{}
+	err := fs.WalkDir(os.DirFS("."), ".", func(dpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() != ".git" {
+			return nil
+		}
+		rep := c.LocalRepository(path.Dir(dpath))
+		if !m.Ignored(rep.Name) {
+			reps = append(reps, rep)
+		}
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error finding Git repositories: %w", err)
+	}
+	s, err := NewSelection(ctx, c, m)
+	if err != nil {
+		return nil, err
+	}
+	return SelectRepositories(ctx, s, reps)
+}
+
+// skipModuleDir returns whether the given directory entry at the
+// given path is a directory that can not contain relevant Go modules,
+// in which case it should be skipped when walking the filesystem.
+func skipModuleDir(dpath string, d fs.DirEntry) bool {
+	// the go command ignores these directories, so we do too
+	return d.IsDir() && dpath != "." && (d.Name() == "testdata" || strings.HasPrefix(d.Name(), ".") || strings.HasPrefix(d.Name(), "_"))
+}
+
+// readModule returns the [Module] for the go.mod file at the given
+// slash-separated path. It returns nil if the module does not have
+// the configured vanity import path prefix.
+func readModule(c *Config, dpath string) (*Module, error) {
+	dir := path.Dir(dpath)
+	b, err := os.ReadFile(dpath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading mod file for %q: %w", dir, err)
+	}
+	mf, err := modfile.Parse(dpath, b, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing mod file for %q: %w", dir, err)
+	}
+	// we only care about modules with vanity import URLs
+	if !c.IsVanityPath(mf.Module.Mod.Path) {
+		return nil, nil
+	}
+	mod := &Module{
+		Path:     mf.Module.Mod.Path,
+		Dir:      dir,
+		Requires: map[string]string{},
+	}
+	for _, req := range mf.Require {
+		// we only care about dependencies with vanity import URLs
+		if !c.IsVanityPath(req.Mod.Path) {
+			continue
+		}
+		mod.GokiImports = append(mod.GokiImports, req.Mod.Path)
+		mod.Requires[req.Mod.Path] = req.Mod.Version
+	}
+	return mod, nil
+}
+
+// groupModules groups the given modules into the Git repositories
+// that contain them, which are found by looking for the closest
+// parent directory of each module that contains a .git directory.
+// The resulting repositories are sorted by directory.
+func groupModules(c *Config, mods []*Module) []*Repository {
+	repsm := map[string]*Repository{}
+	for _, mod := range mods {
+		dir := findRepositoryDir(mod.Dir)
+		rep := repsm[dir]
+		if rep == nil {
+			rep = c.LocalRepository(dir)
+			repsm[dir] = rep
+		}
+		rep.Modules = append(rep.Modules, mod)
+	}
+	res := make([]*Repository, 0, len(repsm))
+	for _, rep := range repsm {
+		slices.SortFunc(rep.Modules, func(a, b *Module) int {
+			return strings.Compare(a.Dir, b.Dir)
+		})
+		// the root module is the one with the shortest directory,
+		// so it is first after sorting
+		rep.VanityURL = rep.Modules[0].Path
+		for _, mod := range rep.Modules {
+			for _, imp := range mod.GokiImports {
+				if rep.Module(imp) != nil || slices.Contains(rep.GokiImports, imp) {
 					continue
 				}
-				rep.GokiImports = append(rep.GokiImports, req.Mod.Path)
+				rep.GokiImports = append(rep.GokiImports, imp)
 			}
-			res = append(res, rep)
-		}()
-		return nil
+		}
+		res = append(res, rep)
+	}
+	slices.SortFunc(res, func(a, b *Repository) int {
+		return strings.Compare(a.Dir, b.Dir)
 	})
-	wg.Wait()
-	return res, errors.Join(errs...)
+	return res
+}
+
+// findRepositoryDir returns the closest slash-separated parent directory
+// of the given slash-separated directory (including the directory itself)
+// that contains a .git directory or file. If there is no such directory,
+// it returns the first element of the given directory.
+func findRepositoryDir(dir string) string {
+	for d := dir; ; d = path.Dir(d) {
+		if _, err := os.Stat(filepath.Join(filepath.FromSlash(d), ".git")); err == nil {
+			return d
+		}
+		if d == "." || d == "/" {
+			break
+		}
+	}
+	return repositoryOfDir(dir)
+}
+
+// Module returns the module of the repository with
+// the given module path, or nil if there is none.
+func (rep *Repository) Module(modPath string) *Module {
+	for _, mod := range rep.Modules {
+		if mod.Path == modPath {
+			return mod
+		}
+	}
+	return nil
+}
+
+// RequiredVersions returns the sorted distinct versions of the modules
+// of the given dependency that are required by the modules of the
+// repository in their go.mod files.
+func (rep *Repository) RequiredVersions(dep *Repository) []string {
+	res := []string{}
+	for _, mod := range rep.Modules {
+		for _, dmod := range dep.Modules {
+			v, ok := mod.Requires[dmod.Path]
+			if ok && !slices.Contains(res, v) {
+				res = append(res, v)
+			}
+		}
+	}
+	semver.Sort(res)
+	return res
+}
+
+// RepositoryNames returns the names of the given repositories.
+func RepositoryNames(reps []*Repository) []string {
+	res := make([]string, len(reps))
+	for i, rep := range reps {
+		res[i] = rep.Name
+	}
+	return res
 }
 
 // GetWebsiteRepositories gets all of the Goki Go repositories as [Repository]
-// objects from the https://goki.dev/repositories page.
-func GetWebsiteRepositories() ([]*Repository, error) {
-	resp, err := http.Get("https://goki.dev/repositories")
+// objects from the repositories page at the given URL, which is typically
+// https://goki.dev/repositories. The URLs of the repositories are based on the
+// given configuration information. The last successfully fetched list is stored
+// in a cache file in the user cache directory, which is used with a staleness
+// warning if the page can not be fetched (eg: when the network is unavailable).
+// If refresh is true, the cache is only updated, and failing to fetch the page
+// is an error.
+func GetWebsiteRepositories(ctx context.Context, c *Config, url string, refresh bool) ([]*Repository, error) {
+	reps, err := getWebsiteRepositories(ctx, url, refresh)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Repository, len(reps))
+	for i, rep := range reps {
+		res[i] = c.NewRepository(rep.Name)
+		res[i].Title = rep.Title
+	}
+	return res, nil
+}
+
+// getWebsiteRepositories gets the names and titles of all of the Goki Go
+// repositories from the repositories page at the given URL, using the cache
+// as described in [GetWebsiteRepositories].
+func getWebsiteRepositories(ctx context.Context, url string, refresh bool) ([]*Repository, error) {
+	reps, err := fetchWebsiteRepositories(ctx, url)
+	if err == nil {
+		cerr := saveRepositoryCache(url, reps)
+		if cerr != nil {
+			slog.Warn("could not cache repository list", "err", cerr)
+		}
+		return reps, nil
+	}
+	if refresh {
+		return nil, err
+	}
+	rc, cerr := loadRepositoryCache(url)
+	if cerr != nil {
+		slog.Debug("no usable repository cache", "err", cerr)
+		return nil, err
+	}
+	slog.Warn("could not fetch repositories page; using stale cached repository list", "url", url, "age", time.Since(rc.Time).Round(time.Minute), "err", err)
+	return rc.Repositories, nil
+}
+
+// fetchWebsiteRepositories fetches all of the Goki Go repositories
+// as [Repository] objects from the repositories page at the given URL.
+func fetchWebsiteRepositories(ctx context.Context, url string) ([]*Repository, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error getting goki.dev/repositories page: %w", err)
+		return nil, fmt.Errorf("error getting repositories page %q: %w", url, err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("got status code %d from goki.dev/repositories (expected 200)", resp.StatusCode)
+		return nil, fmt.Errorf("got status code %d from repositories page %q (expected 200)", resp.StatusCode, url)
 	}
 	tree, err := html.Parse(resp.Body)
 	if err != nil {
@@ -116,7 +340,9 @@ func GetWebsiteRepositories() ([]*Repository, error) {
 }
 
 // extractRepositories extracts repositories from the given HTML node
-// that should be the root node of the https://goki.dev/repositories page.
+// that should be the root node of a repositories page like
+// https://goki.dev/repositories. It only sets the names and titles
+// of the repositories.
 func extractRepositories(node *html.Node) ([]*Repository, error) {
 	nodes := appendAll(nil, node, func(n *html.Node) bool {
 		if n.DataAtom != atom.Div {
@@ -157,8 +383,6 @@ func extractRepositories(node *html.Node) ([]*Repository, error) {
 			Name:  path.Base(href),
 			Title: a.FirstChild.Data,
 		}
-		rep.RepositoryURL = "https://github.com/goki/" + rep.Name
-		rep.VanityURL = "goki.dev/" + rep.Name
 		res = append(res, rep)
 	}
 	return res, nil
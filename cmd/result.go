@@ -0,0 +1,326 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Status is the outcome of running a command on one repository.
+type Status string
+
+const (
+	// StatusOK indicates that the command succeeded without changing anything
+	StatusOK Status = "ok"
+
+	// StatusChanged indicates that the command succeeded and changed
+	// the repository, or, for commands that only inspect repositories,
+	// that the repository has changes
+	StatusChanged Status = "changed"
+
+	// StatusSkipped indicates that the command did not need to do anything
+	StatusSkipped Status = "skipped"
+
+	// StatusFailed indicates that the command failed
+	StatusFailed Status = "failed"
+
+	// StatusIncomplete indicates that the command was interrupted
+	// before it started or finished running on the repository
+	StatusIncomplete Status = "incomplete"
+)
+
+// Result is the result of running a command on one repository.
+type Result struct {
+
+	// Repository is the repository, with any fields
+	// set by the command (eg: Changed and Version)
+	Repository *Repository
+
+	// Status is the outcome of the command
+	Status Status
+
+	// Duration is how long the command took
+	Duration time.Duration
+
+	// Err is the error of the command, if it failed
+	Err error
+}
+
+// Results are the results of running a command on multiple repositories,
+// in the order of the repositories.
+type Results []*Result
+
+// RunRepositories concurrently calls the given function on each of the
+// given repositories using [Map], recording the [Result] of each call.
+// Calls that return an error have a status of [StatusFailed], or
+// [StatusIncomplete] if the error was caused by an interrupt. Repositories
+// that are not started because of an interrupt also have a status of
+// [StatusIncomplete]. The function is passed a context containing a
+// [RepositoryOutput] for the repository, which captures the output of the
+// commands run on it with the context (see [NewRepositoryOutput]).
+func RunRepositories(ctx context.Context, c *Config, reps []*Repository, fun func(ctx context.Context, rep *Repository) (Status, error)) Results {
+	logDir, lerr := c.RunLogDir()
+	rs, err := Map(ctx, c, reps, func(rep *Repository) (*Result, error) {
+		if lerr != nil {
+			return &Result{Repository: rep, Status: StatusFailed, Err: lerr}, nil
+		}
+		ro, err := NewRepositoryOutput(c, logDir, rep)
+		if err != nil {
+			return &Result{Repository: rep, Status: StatusFailed, Err: err}, nil
+		}
+		start := time.Now()
+		status, err := fun(context.WithValue(ctx, outputKey{}, ro), rep)
+		if err != nil {
+			status = StatusFailed
+			if errors.Is(err, ErrInterrupted) {
+				status = StatusIncomplete
+			}
+		}
+		r := &Result{Repository: rep, Status: status, Duration: time.Since(start), Err: err}
+		if err := ro.Close(r); err != nil && r.Err == nil {
+			r.Status, r.Err = StatusFailed, fmt.Errorf("error writing log file: %w", err)
+		}
+		return r, nil
+	})
+	if logDir != "" {
+		fmt.Fprintln(os.Stderr, "Wrote logs to", logDir)
+	}
+	if err == nil {
+		return rs
+	}
+	// the only errors are for repositories that were not started
+	for i, rep := range reps {
+		if rs[i] == nil {
+			rs[i] = &Result{Repository: rep, Status: StatusIncomplete, Err: fmt.Errorf("not started: %w", context.Cause(ctx))}
+		}
+	}
+	return rs
+}
+
+// Count returns the number of results with the given status.
+func (rs Results) Count(status Status) int {
+	n := 0
+	for _, r := range rs {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Print prints a summary table of the results to the given writer,
+// followed by the number of results with each status. If any of the
+// repositories have local changes recorded in [Repository.Changes],
+// the table includes their categories.
+func (rs Results) Print(w io.Writer) error {
+	if len(rs) == 0 {
+		_, err := fmt.Fprintln(w, "No repositories")
+		return err
+	}
+	changes := slices.ContainsFunc(rs, func(r *Result) bool { return len(r.Repository.Changes) > 0 })
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if changes {
+		fmt.Fprintln(tw, "REPOSITORY\tSTATUS\tCHANGES\tDURATION\tERROR")
+	} else {
+		fmt.Fprintln(tw, "REPOSITORY\tSTATUS\tDURATION\tERROR")
+	}
+	for _, r := range rs {
+		msg := "-"
+		if r.Err != nil {
+			// only the first line fits in the table
+			msg, _, _ = strings.Cut(r.Err.Error(), "\n")
+		}
+		if changes {
+			chs := make([]string, len(r.Repository.Changes))
+			for i, ch := range r.Repository.Changes {
+				chs[i] = string(ch)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Repository.Name, r.Status, orDash(strings.Join(chs, ",")), r.Duration.Round(time.Millisecond), msg)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Repository.Name, r.Status, r.Duration.Round(time.Millisecond), msg)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "\n%d ok, %d changed, %d skipped, %d failed", rs.Count(StatusOK), rs.Count(StatusChanged), rs.Count(StatusSkipped), rs.Count(StatusFailed))
+	if err != nil {
+		return err
+	}
+	if n := rs.Count(StatusIncomplete); n > 0 {
+		_, err = fmt.Fprintf(w, ", %d incomplete", n)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+// Err returns a [*ResultsError] if any of the results failed or are
+// incomplete, or nil otherwise.
+func (rs Results) Err() error {
+	failed, incomplete := rs.Count(StatusFailed), rs.Count(StatusIncomplete)
+	if failed == 0 && incomplete == 0 {
+		return nil
+	}
+	errs := []error{}
+	for _, r := range rs {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Repository.Name, r.Err))
+		}
+	}
+	return &ResultsError{Failed: failed, Incomplete: incomplete, Total: len(rs), Err: errors.Join(errs...)}
+}
+
+// Finish writes the results to the given writer in the given output
+// format (see [Results.Write]) and returns [Results.Err], which is the
+// standard way for commands to finish after running on multiple repositories.
+func (rs Results) Finish(w io.Writer, format string) error {
+	err := rs.Write(w, format)
+	if err != nil {
+		return err
+	}
+	return rs.Err()
+}
+
+// Write writes the results to the given writer in the given output format,
+// which is either text (see [Results.Print]), json (a [ResultsJSON] object), or
+// ndjson (a [ResultJSON] object on each line).
+func (rs Results) Write(w io.Writer, format string) error {
+	if format == "text" || format == "" {
+		return rs.Print(w)
+	}
+	rjs := make([]*ResultJSON, len(rs))
+	for i, r := range rs {
+		rjs[i] = r.JSON()
+	}
+	counts := map[Status]int{}
+	for _, status := range []Status{StatusOK, StatusChanged, StatusSkipped, StatusFailed, StatusIncomplete} {
+		counts[status] = rs.Count(status)
+	}
+	return WriteJSON(w, format, &ResultsJSON{Results: rjs, Counts: counts}, rjs)
+}
+
+// ResultsJSON is the JSON representation of [Results]
+// written by [Results.Write] in the json format.
+type ResultsJSON struct {
+
+	// Results are the results for each repository
+	Results []*ResultJSON
+
+	// Counts are the number of results with each status,
+	// including statuses with no results
+	Counts map[Status]int
+}
+
+// ResultJSON is the JSON representation of a [Result].
+type ResultJSON struct {
+
+	// Repository is the repository, with any fields
+	// set by the command (eg: Changed and Version)
+	Repository *Repository
+
+	// Status is the outcome of the command: ok, changed,
+	// skipped, failed, or incomplete
+	Status Status
+
+	// Duration is how long the command took in seconds
+	Duration float64
+
+	// Error is the error message of the command,
+	// or "" if there is no error
+	Error string
+}
+
+// JSON returns the JSON representation of the result.
+func (r *Result) JSON() *ResultJSON {
+	rj := &ResultJSON{Repository: r.Repository, Status: r.Status, Duration: r.Duration.Seconds()}
+	if r.Err != nil {
+		rj.Error = r.Err.Error()
+	}
+	return rj
+}
+
+// ResultsError is the error returned when a command failed
+// for some or all of the repositories it ran on.
+type ResultsError struct {
+
+	// Failed is the number of repositories the command failed for
+	Failed int
+
+	// Incomplete is the number of repositories the command
+	// was interrupted before starting or finishing
+	Incomplete int
+
+	// Total is the total number of repositories the command ran on
+	Total int
+
+	// Err contains the errors of the failed repositories
+	Err error
+}
+
+func (re *ResultsError) Error() string {
+	switch {
+	case re.Incomplete == 0:
+		return fmt.Sprintf("%d of %d repositories failed", re.Failed, re.Total)
+	case re.Failed == 0:
+		return fmt.Sprintf("%d of %d repositories are incomplete", re.Incomplete, re.Total)
+	}
+	return fmt.Sprintf("%d of %d repositories failed and %d are incomplete", re.Failed, re.Total, re.Incomplete)
+}
+
+func (re *ResultsError) Unwrap() error {
+	return re.Err
+}
+
+// The exit codes of gsm, which allow scripts to
+// distinguish between different kinds of failures.
+const (
+	// ExitOK is the exit code when the command succeeded
+	ExitOK = 0
+
+	// ExitError is the exit code when the command failed as a whole
+	ExitError = 1
+
+	// ExitSomeFailed is the exit code when the command failed
+	// for some, but not all, of the repositories it ran on
+	ExitSomeFailed = 2
+
+	// ExitAllFailed is the exit code when the command failed
+	// for all of the repositories it ran on
+	ExitAllFailed = 3
+
+	// ExitInterrupted is the exit code when the command was
+	// interrupted, following the convention of 128 + SIGINT
+	ExitInterrupted = 130
+)
+
+// ExitCode returns the exit code of gsm for the given command error.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if errors.Is(err, ErrInterrupted) {
+		return ExitInterrupted
+	}
+	re := &ResultsError{}
+	if !errors.As(err, &re) {
+		return ExitError
+	}
+	if re.Failed < re.Total {
+		return ExitSomeFailed
+	}
+	return ExitAllFailed
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"time"
+)
+
+// maxRetryDelay is the maximum delay between retries of network operations.
+const maxRetryDelay = time.Minute
+
+// Retry calls the given network operation, retrying it with exponential
+// backoff as specified by the config Retries and RetryDelay if it fails
+// with an error that [IsRetryable] reports as transient. The given name
+// is used to identify the operation in log messages. It stops retrying if
+// the given context is done.
+func Retry(ctx context.Context, c *Config, name string, fun func() error) error {
+	delay := time.Second
+	if c.RetryDelay != "" {
+		var err error
+		delay, err = time.ParseDuration(c.RetryDelay)
+		if err != nil {
+			return fmt.Errorf("invalid retry delay %q: %w", c.RetryDelay, err)
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		err := fun()
+		if err == nil || attempt >= c.Retries || !IsRetryable(err) {
+			return err
+		}
+		slog.Warn("retrying after transient failure", "operation", name, "attempt", attempt+1, "delay", delay, "err", err)
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("%w (not retried: %w)", err, context.Cause(ctx))
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+// retryableRegexp matches the Git error messages of
+// transient network failures that are worth retrying.
+var retryableRegexp = regexp.MustCompile(`(?i)connection (reset|refused|timed out)|operation timed out|could not resolve host|temporary failure in name resolution|network is unreachable|the remote end hung up unexpectedly|early eof|rpc failed|unexpected disconnect|tls connection was non-properly terminated|gnutls recv error|gnutls_handshake\(\) failed: error in the (pull|push) function|returned error: 5\d\d|http 5\d\d|the requested url returned error: 429`)
+
+// permanentRegexp matches the Git error messages of failures that
+// will not be fixed by retrying, like authentication failures, TLS
+// certificate errors, and merge conflicts. It takes precedence over
+// [retryableRegexp].
+var permanentRegexp = regexp.MustCompile(`(?i)authentication failed|permission denied|could not read (username|password)|repository not found|does not appear to be a git repository|conflict|not possible to fast-forward|would be overwritten|not a git repository|certificate|returned error: 4(0[0-9]|1\d)`)
+
+// IsRetryable returns whether the given error from a network Git
+// operation is caused by a transient problem, in which case the
+// operation should be retried. Failures caused by the command timing
+// out are retryable, while interrupts are not.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, ErrInterrupted) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	ce := &CommandError{}
+	if !errors.As(err, &ce) {
+		return false
+	}
+	if permanentRegexp.MatchString(ce.Stderr) {
+		return false
+	}
+	return retryableRegexp.MatchString(ce.Stderr)
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		stderr string
+		want   bool
+	}{
+		{"fatal: the remote end hung up unexpectedly", true},
+		{"error: RPC failed; curl 56 GnuTLS recv error (-54): Error in the pull function.", true},
+		{"fatal: unable to access 'https://github.com/goki/gi/': gnutls_handshake() failed: Error in the pull function.", true},
+		{"fatal: unable to access 'https://github.com/goki/gi/': GnuTLS recv error (-110): The TLS connection was non-properly terminated.", true},
+		{"fatal: unable to access 'https://github.com/goki/gi/': Could not resolve host: github.com", true},
+		{"fatal: unable to access 'https://github.com/goki/gi/': Failed to connect to github.com port 443: Connection refused", true},
+		{"fatal: unable to access 'https://github.com/goki/gi/': The requested URL returned error: 502", true},
+		{"fatal: unable to access 'https://github.com/goki/gi/': The requested URL returned error: 429", true},
+		{"fatal: early EOF", true},
+		{"fatal: unable to access 'https://github.com/goki/gi/': server certificate verification failed. CAfile: none CRLfile: none", false},
+		{"fatal: unable to access 'https://github.com/goki/gi/': gnutls_handshake() failed: The certificate is NOT trusted.", false},
+		{"fatal: Authentication failed for 'https://github.com/goki/gi/'", false},
+		{"git@github.com: Permission denied (publickey).\nfatal: Could not read from remote repository.", false},
+		{"remote: Repository not found.\nfatal: repository 'https://github.com/goki/nope/' not found", false},
+		{"fatal: unable to access 'https://github.com/goki/gi/': The requested URL returned error: 403", false},
+		{"fatal: Not possible to fast-forward, aborting.", false},
+		{"CONFLICT (content): Merge conflict in gi.go", false},
+		{"fatal: the remote end hung up unexpectedly\nfatal: Authentication failed", false},
+		{"fatal: pathspec 'x' did not match any files", false},
+	}
+	for _, test := range tests {
+		err := fmt.Errorf("error pulling: %w", &CommandError{Command: "git pull", Stderr: test.stderr, Err: errors.New("exit status 128")})
+		if got := IsRetryable(err); got != test.want {
+			t.Errorf("expected IsRetryable for %q to be %v, but got %v", test.stderr, test.want, got)
+		}
+	}
+	if !IsRetryable(fmt.Errorf("timed out: %w", context.DeadlineExceeded)) {
+		t.Errorf("expected timeouts to be retryable")
+	}
+	if IsRetryable(fmt.Errorf("%w: %w", ErrInterrupted, context.DeadlineExceeded)) {
+		t.Errorf("expected interrupts to not be retryable")
+	}
+	if IsRetryable(errors.New("fatal: the remote end hung up unexpectedly")) {
+		t.Errorf("expected errors that are not command errors to not be retryable")
+	}
+}
+
+// delayRecorder is a [slog.Handler] that records the delays
+// logged by [Retry] before each retry.
+type delayRecorder struct {
+	delays []time.Duration
+}
+
+func (dr *delayRecorder) Enabled(context.Context, slog.Level) bool { return true }
+func (dr *delayRecorder) WithAttrs([]slog.Attr) slog.Handler       { return dr }
+func (dr *delayRecorder) WithGroup(string) slog.Handler            { return dr }
+
+func (dr *delayRecorder) Handle(_ context.Context, r slog.Record) error {
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "delay" {
+			dr.delays = append(dr.delays, a.Value.Duration())
+		}
+		return true
+	})
+	return nil
+}
+
+func TestRetry(t *testing.T) {
+	transient := &CommandError{Command: "git pull", Stderr: "fatal: the remote end hung up unexpectedly", Err: errors.New("exit status 128")}
+	permanent := &CommandError{Command: "git pull", Stderr: "fatal: Authentication failed", Err: errors.New("exit status 128")}
+	tests := []struct {
+		name     string
+		retries  int
+		failures int
+		err      error
+		attempts int
+		delays   []time.Duration
+		wantErr  bool
+	}{
+		{"success", 3, 0, transient, 1, nil, false},
+		{"transient then success", 3, 2, transient, 3, []time.Duration{time.Millisecond, 2 * time.Millisecond}, false},
+		{"too many transient", 2, 5, transient, 3, []time.Duration{time.Millisecond, 2 * time.Millisecond}, true},
+		{"permanent", 3, 5, permanent, 1, nil, true},
+		{"no retries", 0, 5, transient, 1, nil, true},
+	}
+	old := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(old) })
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dr := &delayRecorder{}
+			slog.SetDefault(slog.New(dr))
+			c := &Config{Retries: test.retries, RetryDelay: "1ms"}
+			attempts := 0
+			err := Retry(context.Background(), c, "pull", func() error {
+				attempts++
+				if attempts <= test.failures {
+					return test.err
+				}
+				return nil
+			})
+			if (err != nil) != test.wantErr {
+				t.Errorf("expected error %v, but got %v", test.wantErr, err)
+			}
+			if attempts != test.attempts {
+				t.Errorf("expected %d attempts, but got %d", test.attempts, attempts)
+			}
+			if fmt.Sprint(dr.delays) != fmt.Sprint(test.delays) {
+				t.Errorf("expected delays %v, but got %v", test.delays, dr.delays)
+			}
+		})
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	c := &Config{Retries: 3, RetryDelay: "1h"}
+	attempts := 0
+	err := Retry(ctx, c, "pull", func() error {
+		attempts++
+		cancel(ErrInterrupted)
+		return &CommandError{Command: "git pull", Stderr: "fatal: early EOF", Err: errors.New("exit status 128")}
+	})
+	if attempts != 1 || !errors.Is(err, ErrInterrupted) {
+		t.Errorf("expected one attempt and an interrupted error, but got %d attempts and %v", attempts, err)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"goki.dev/grog"
+	"goki.dev/xe"
+)
+
+// ErrInterrupted is the cause of the cancellation of the
+// contexts returned by [Config.Context] on an interrupt signal.
+var ErrInterrupted = errors.New("interrupted")
+
+// execKey is the context key for [execSettings]
+type execKey struct{}
+
+// execSettings are the settings for running commands
+// with [Run] and [Output] stored in a context.
+type execSettings struct {
+
+	// abort is canceled when running commands should be aborted
+	abort context.Context
+
+	// timeout is the maximum duration of each command, or 0 for no limit
+	timeout time.Duration
+}
+
+// Context returns the context that a gsm command should run with, along with a
+// function to call when the command is done. The context is canceled on the first
+// interrupt signal, after which no new work should be started, but the steps that
+// are already running for each repository are allowed to finish. A second interrupt
+// signal aborts all running external commands. Each external command run with [Run]
+// or [Output] with the context is also limited to the config Timeout. (Note that
+// interrupt signals sent from a terminal are also received by the external commands
+// themselves, so those that handle them will still stop early.) If the config Format
+// is json or ndjson, it also suppresses the informational output of gsm so that
+// standard output only contains JSON. It returns an error if the config contains
+// invalid settings for running commands.
+func (c *Config) Context() (context.Context, context.CancelFunc, error) {
+	err := checkFormat(c.Format)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch c.Output {
+	case "block", "stream", "":
+	default:
+		return nil, nil, fmt.Errorf("unknown output mode %q (must be block or stream)", c.Output)
+	}
+	if IsJSON(c.Format) {
+		quietStdout()
+	}
+	timeout := time.Duration(0)
+	if c.Timeout != "" {
+		timeout, err = time.ParseDuration(c.Timeout)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid timeout %q: %w", c.Timeout, err)
+		}
+	}
+	ctx, cancel := context.WithCancelCause(context.Background())
+	abort, cancelAbort := context.WithCancelCause(context.Background())
+	ctx = context.WithValue(ctx, execKey{}, &execSettings{abort: abort, timeout: timeout})
+	if c.git != nil {
+		ctx = context.WithValue(ctx, gitKey{}, c.git)
+	}
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-sigs:
+			slog.Warn("interrupted; waiting for running steps to finish (interrupt again to abort them)")
+			cancel(ErrInterrupted)
+		case <-done:
+			return
+		}
+		select {
+		case <-sigs:
+			slog.Warn("interrupted again; aborting running steps")
+			cancelAbort(ErrInterrupted)
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigs)
+		close(done)
+		cancel(context.Canceled)
+		cancelAbort(context.Canceled)
+	}, nil
+}
+
+// Run runs the given command with the given arguments using the given xe config,
+// like [xe.Config.Run], except that the command is stopped if it exceeds the timeout
+// or is aborted as specified by the given context (see [Config.Context]). If the
+// context contains a [RepositoryOutput], the output is redirected to it.
+func Run(ctx context.Context, xc *xe.Config, cmd string, args ...string) error {
+	xc, ro := withOutput(ctx, xc)
+	return run(ctx, xc, ro, xc.Stdout, cmd, args...)
+}
+
+// Output is like [Run], except that it also returns the standard
+// output of the command, like [xe.Config.Output].
+func Output(ctx context.Context, xc *xe.Config, cmd string, args ...string) (string, error) {
+	xc, ro := withOutput(ctx, xc)
+	buf := &bytes.Buffer{}
+	var stdout io.Writer = buf
+	if xc.Stdout != nil {
+		stdout = io.MultiWriter(buf, xc.Stdout)
+	}
+	err := run(ctx, xc, ro, stdout, cmd, args...)
+	return strings.TrimSuffix(buf.String(), "\n"), err
+}
+
+// run is the implementation of [Run] and [Output], writing the standard output
+// of the command to the given writer and logging the command to the given
+// repository output if it is non-nil.
+func run(ctx context.Context, xc *xe.Config, ro *RepositoryOutput, stdout io.Writer, cmd string, args ...string) error {
+	rctx := context.Background()
+	es, _ := ctx.Value(execKey{}).(*execSettings)
+	if es != nil {
+		rctx = es.abort
+		if es.timeout > 0 {
+			var cancel context.CancelFunc
+			rctx, cancel = context.WithTimeoutCause(rctx, es.timeout, fmt.Errorf("timed out after %v: %w", es.timeout, context.DeadlineExceeded))
+			defer cancel()
+		}
+	}
+	if err := context.Cause(rctx); err != nil {
+		return fmt.Errorf("not running %q: %w", cmd, err)
+	}
+
+	cstr := strings.TrimSpace(cmd + " " + strings.Join(args, " "))
+	cm := exec.CommandContext(rctx, cmd, args...)
+	cm.Dir = xc.Dir
+	cm.Stdin = xc.Stdin
+	cm.Env = os.Environ()
+	for k, v := range xc.Env {
+		cm.Env = append(cm.Env, k+"="+v)
+	}
+	// give the command a chance to exit cleanly after it is interrupted
+	cm.Cancel = func() error {
+		return cm.Process.Signal(os.Interrupt)
+	}
+	cm.WaitDelay = 5 * time.Second
+
+	// we buffer like xe so that the command and its output
+	// are printed together and only when they are relevant
+	obuf, ebuf := &bytes.Buffer{}, &bytes.Buffer{}
+	cm.Stdout, cm.Stderr = obuf, ebuf
+	if xc.Commands != nil {
+		xc.PrintCmd(cstr, nil)
+	}
+
+	err := cm.Run()
+	if err != nil && rctx.Err() != nil {
+		err = fmt.Errorf("%w: %w", context.Cause(rctx), err)
+	}
+
+	if ro != nil {
+		ro.logCommand(cstr, obuf.Bytes(), ebuf.Bytes(), err)
+	}
+	if xc.Commands == nil {
+		xc.PrintCmd(cstr, err)
+	}
+	if w := xc.GetWriter(stdout, err); w != nil {
+		w.Write(obuf.Bytes())
+	}
+	if ebuf.Len() > 0 && xc.Stderr != nil {
+		xc.Stderr.Write([]byte(grog.ErrorColor(ebuf.String())))
+	}
+	if err != nil {
+		return &CommandError{Command: cstr, Stderr: ebuf.String(), Err: err}
+	}
+	return nil
+}
+
+// CommandError is the error returned by [Run] and [Output]
+// when an external command fails.
+type CommandError struct {
+
+	// Command is the command with its arguments
+	Command string
+
+	// Stderr is the standard error output of the command,
+	// which can be used to determine why it failed
+	Stderr string
+
+	// Err is the underlying error
+	Err error
+}
+
+func (ce *CommandError) Error() string {
+	return fmt.Sprintf("failed to run %q: %v", ce.Command, ce.Err)
+}
+
+func (ce *CommandError) Unwrap() error {
+	return ce.Err
+}
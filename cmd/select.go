@@ -0,0 +1,162 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+)
+
+// Selection determines which repositories commands run on
+// based on the selector fields of a [Config] (Repos, Exclude,
+// Group, ChangedOnly, and Closure) and the workspace manifest.
+type Selection struct {
+
+	// Config is the config containing the selector fields
+	Config *Config
+
+	// Manifest is the workspace manifest, which contains the groups
+	Manifest *Manifest
+
+	// closure contains the names of the repositories in the dependency
+	// closure of the config Closure repository, if it is specified
+	closure map[string]bool
+}
+
+// NewSelection returns a new [Selection] for the given config and workspace
+// manifest. If a dependency closure is specified in the config, it computes it
+// from the dependency graph of the local repositories.
+func NewSelection(ctx context.Context, c *Config, m *Manifest) (*Selection, error) {
+	s := &Selection{Config: c, Manifest: m}
+	if c.Closure == "" {
+		return s, nil
+	}
+	reps, err := GetLocalRepositories(ctx, c)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing packages: %w", err)
+	}
+	// an incomplete graph only affects dependencies outside of the workspace
+	g, _ := NewDependencyGraph(reps)
+	root := g.RepositoryByName(c.Closure)
+	if root == nil {
+		return nil, fmt.Errorf("repository %q not found", c.Closure)
+	}
+	s.closure = map[string]bool{}
+	for _, rep := range g.Closure(root) {
+		s.closure[rep.Name] = true
+	}
+	return s, nil
+}
+
+// Matches returns whether the repository with the given name is selected
+// by the name, exclude, group, and closure selectors. The name is matched
+// against the patterns both as is and by its last element, so that
+// repositories in subdirectories can be selected by their base name.
+func (s *Selection) Matches(name string) bool {
+	c := s.Config
+	if len(c.Repos) > 0 && !matchAny(c.Repos, name) {
+		return false
+	}
+	if matchAny(c.Exclude, name) {
+		return false
+	}
+	if len(c.Group) > 0 {
+		mr := s.Manifest.Repository(name)
+		if mr == nil || !slices.Contains(c.Group, mr.Group) {
+			return false
+		}
+	}
+	if s.closure != nil && !s.closure[path.Base(name)] {
+		return false
+	}
+	return true
+}
+
+// matchAny returns whether the given slash-separated name or its last
+// element matches any of the given patterns (in the format of [path.Match]).
+func matchAny(patterns []string, name string) bool {
+	for _, pat := range patterns {
+		if ok, _ := path.Match(pat, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pat, path.Base(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SelectItems returns the given items (typically repositories or their
+// directories) that are selected by the given selection, in the same order.
+// The name function returns the slash-separated name of the repository of an
+// item, and the dir function returns its directory on the local filesystem,
+// which is only used for checking for changes if ChangedOnly is set.
+func SelectItems[T any](ctx context.Context, s *Selection, items []T, name func(item T) string, dir func(item T) string) ([]T, error) {
+	res := []T{}
+	for _, item := range items {
+		if s.Matches(name(item)) {
+			res = append(res, item)
+		}
+	}
+	if !s.Config.ChangedOnly {
+		return res, nil
+	}
+	changed, err := Map(ctx, s.Config, res, func(item T) (bool, error) {
+		d := dir(item)
+		if _, err := os.Stat(d); err != nil { // repositories that don't exist locally have no changes
+			return false, nil
+		}
+		return HasLocalChanges(ctx, d)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sel := []T{}
+	for i, item := range res {
+		if changed[i] {
+			sel = append(sel, item)
+		}
+	}
+	return sel, nil
+}
+
+// SelectRepositories returns the given repositories that are
+// selected by the given selection, in the same order.
+func SelectRepositories(ctx context.Context, s *Selection, reps []*Repository) ([]*Repository, error) {
+	return SelectItems(ctx, s, reps, func(rep *Repository) string {
+		if rep.Dir == "" {
+			return rep.Name
+		}
+		return rep.Dir
+	}, func(rep *Repository) string {
+		if rep.Dir == "" {
+			return rep.Name
+		}
+		return filepath.FromSlash(rep.Dir)
+	})
+}
+
+// HasLocalChanges returns whether the Git repository in the given directory
+// has changes that need to be updated in version control (see [LocalChanges]).
+func HasLocalChanges(ctx context.Context, dir string) (bool, error) {
+	changes, err := LocalChanges(ctx, dir)
+	return len(changes) > 0, err
+}
+
+// LocalChanges returns the categories of changes that need to be updated in
+// version control in the Git repository in the given directory, which are
+// uncommitted changes (including untracked files) and commits that have not
+// been pushed to its upstream (see [GitStatus.Changes]).
+func LocalChanges(ctx context.Context, dir string) ([]Change, error) {
+	st, err := GitFrom(ctx).Status(ctx, dir)
+	if err != nil {
+		return nil, fmt.Errorf("error getting status of %q: %w", dir, err)
+	}
+	return st.Changes(), nil
+}
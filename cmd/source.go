@@ -0,0 +1,215 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// RepositorySource is a source of the Goki repositories that
+// commands like [Clone] operate on.
+type RepositorySource interface {
+
+	// Repositories returns all of the repositories from the source.
+	Repositories(ctx context.Context) ([]*Repository, error)
+}
+
+// NewRepositorySource returns the [RepositorySource] specified
+// by the given configuration information.
+func NewRepositorySource(c *Config) (RepositorySource, error) {
+	switch c.Source {
+	case "website", "":
+		return &WebsiteSource{Config: c, URL: c.SourceURL, Refresh: c.Refresh}, nil
+	case "manifest":
+		return &ManifestSource{Config: c, File: c.Manifest}, nil
+	case "work":
+		return &WorkSource{Config: c, File: c.SourceURL}, nil
+	case "github":
+		return &GitHubSource{Config: c, URL: c.SourceURL}, nil
+	}
+	return nil, fmt.Errorf("unknown repository source %q (must be website, manifest, work, or github)", c.Source)
+}
+
+// WebsiteSource is a [RepositorySource] that gets repositories
+// from a repositories page like https://goki.dev/repositories.
+type WebsiteSource struct {
+
+	// Config is the configuration information used
+	// to determine the URLs of the repositories
+	Config *Config
+
+	// URL is the URL of the repositories page. If it is unset,
+	// the repositories page of the configured vanity domain is used.
+	URL string
+
+	// Refresh is whether to fail if the repositories page can not
+	// be fetched, instead of falling back on the cached list.
+	Refresh bool
+}
+
+func (ws *WebsiteSource) Repositories(ctx context.Context) ([]*Repository, error) {
+	url := ws.URL
+	if url == "" {
+		url = "https://" + ws.Config.Vanity + "/repositories"
+	}
+	return GetWebsiteRepositories(ctx, ws.Config, url, ws.Refresh)
+}
+
+// ManifestSource is a [RepositorySource] that gets repositories
+// from the repositories listed in a workspace [Manifest] file.
+type ManifestSource struct {
+
+	// Config is the configuration information used
+	// to determine the URLs of the repositories
+	Config *Config
+
+	// File is the path of the manifest file
+	File string
+}
+
+func (ms *ManifestSource) Repositories(ctx context.Context) ([]*Repository, error) {
+	m, err := LoadManifest(ms.File)
+	if err != nil {
+		return nil, err
+	}
+	res := []*Repository{}
+	for _, mr := range m.Repositories {
+		rep := ms.Config.NewRepository(mr.Name)
+		if mr.Remote != "" {
+			rep.RepositoryURL = mr.Remote
+		}
+		res = append(res, rep)
+	}
+	return res, nil
+}
+
+// WorkSource is a [RepositorySource] that gets repositories
+// from the modules used in an existing go.work file, grouping
+// them into the Git repositories that contain them.
+type WorkSource struct {
+
+	// Config is the configuration information used to determine
+	// which modules are Goki modules and the URLs of the repositories
+	Config *Config
+
+	// File is the path of the go.work file.
+	// If it is unset, "go.work" is used.
+	File string
+}
+
+func (ws *WorkSource) Repositories(ctx context.Context) ([]*Repository, error) {
+	file := ws.File
+	if file == "" {
+		file = "go.work"
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading work file: %w", err)
+	}
+	work, err := modfile.ParseWork(file, b, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing work file %q: %w", file, err)
+	}
+	mods := []*Module{}
+	errs := []error{}
+	for _, use := range work.Use {
+		dpath := path.Join(filepath.ToSlash(filepath.Dir(file)), use.Path, "go.mod")
+		mod, err := readModule(ws.Config, dpath)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if mod != nil {
+			mods = append(mods, mod)
+		}
+	}
+	return groupModules(ws.Config, mods), errors.Join(errs...)
+}
+
+// GitHubSource is a [RepositorySource] that gets repositories from
+// a JSON repository listing in the format of the GitHub organization
+// repositories API (https://docs.github.com/en/rest/repos/repos).
+// Archived repositories and forks are not included.
+type GitHubSource struct {
+
+	// Config is the configuration information used to determine
+	// the vanity import URLs of the repositories
+	Config *Config
+
+	// URL is the URL of the JSON repository listing. If it is unset,
+	// the GitHub API listing of the configured organization is used.
+	URL string
+}
+
+// gitHubRepository is the subset of a repository object
+// in a GitHub repository listing that is used by [GitHubSource].
+type gitHubRepository struct {
+	Name     string `json:"name"`
+	HTMLURL  string `json:"html_url"`
+	Archived bool   `json:"archived"`
+	Fork     bool   `json:"fork"`
+}
+
+func (gs *GitHubSource) Repositories(ctx context.Context) ([]*Repository, error) {
+	url := gs.URL
+	if url == "" {
+		url = "https://api.github.com/orgs/" + gs.Config.Org + "/repos?per_page=100"
+	}
+	res := []*Repository{}
+	// we follow the pagination links until there are no more pages
+	for url != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error getting repository listing %q: %w", url, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("got status code %d from repository listing %q (expected 200)", resp.StatusCode, url)
+		}
+		var ghreps []gitHubRepository
+		err = json.NewDecoder(resp.Body).Decode(&ghreps)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding repository listing %q: %w", url, err)
+		}
+		for _, ghrep := range ghreps {
+			if ghrep.Archived || ghrep.Fork {
+				continue
+			}
+			rep := gs.Config.NewRepository(ghrep.Name)
+			rep.RepositoryURL = ghrep.HTMLURL
+			res = append(res, rep)
+		}
+		url = nextLink(resp.Header.Get("Link"))
+	}
+	return res, nil
+}
+
+// nextLink returns the URL with rel="next" in the given
+// Link HTTP header, or "" if there is no such URL.
+func nextLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		url, params, ok := strings.Cut(link, ";")
+		if !ok || !strings.Contains(params, `rel="next"`) {
+			continue
+		}
+		return strings.Trim(strings.TrimSpace(url), "<>")
+	}
+	return ""
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testSourceConfig returns the config used by the repository source tests.
+func testSourceConfig() *Config {
+	return &Config{Vanity: "goki.dev", Host: "github.com", Org: "goki", Protocol: "https"}
+}
+
+// repositorySummary returns a summary of the given repositories
+// in the form name=url, separated by spaces.
+func repositorySummary(reps []*Repository) string {
+	res := []string{}
+	for _, rep := range reps {
+		res = append(res, rep.Name+"="+rep.RepositoryURL)
+	}
+	return strings.Join(res, " ")
+}
+
+func TestGitHubSource(t *testing.T) {
+	pages := map[string]string{
+		"1": `[
+			{"name": "gi", "html_url": "https://github.com/goki/gi"},
+			{"name": "oldgi", "html_url": "https://github.com/goki/oldgi", "archived": true},
+			{"name": "forked", "html_url": "https://github.com/goki/forked", "fork": true}
+		]`,
+		"2": `[{"name": "goosi", "html_url": "https://github.com/goki/goosi"}]`,
+		"3": `[{"name": "girl", "html_url": "https://github.com/goki/girl", "archived": false, "fork": false}]`,
+	}
+	requests := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		requests = append(requests, page)
+		body, ok := pages[page]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		links := []string{}
+		if page != "3" {
+			next := fmt.Sprint(page[0] - '0' + 1)
+			links = append(links, fmt.Sprintf(`<http://%s/repos?page=%s>; rel="next"`, r.Host, next))
+		}
+		links = append(links, fmt.Sprintf(`<http://%s/repos?page=3>; rel="last"`, r.Host))
+		w.Header().Set("Link", strings.Join(links, ", "))
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, body)
+	}))
+	defer srv.Close()
+
+	gs := &GitHubSource{Config: testSourceConfig(), URL: srv.URL + "/repos?page=1"}
+	reps, err := gs.Repositories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "gi=https://github.com/goki/gi goosi=https://github.com/goki/goosi girl=https://github.com/goki/girl"
+	if got := repositorySummary(reps); got != want {
+		t.Errorf("expected repositories %q, but got %q", want, got)
+	}
+	if got := strings.Join(requests, " "); got != "1 2 3" {
+		t.Errorf("expected requests for pages 1 2 3, but got %q", got)
+	}
+	if reps[0].VanityURL != "goki.dev/gi" {
+		t.Errorf("expected vanity URL goki.dev/gi, but got %q", reps[0].VanityURL)
+	}
+
+	gs.URL = srv.URL + "/repos?page=4"
+	_, err = gs.Repositories(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "status code 404") {
+		t.Errorf("expected a status code error, but got %v", err)
+	}
+}
+
+func TestWebsiteSource(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	entry := `<div class="entry"><h5><a href="/%s/">%s</a></h5></div>`
+	page := fmt.Sprintf(entry, "gi", "GI")
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintf(w, "<html><body>%s</body></html>", page)
+	}))
+	defer srv.Close()
+
+	ws := &WebsiteSource{Config: testSourceConfig(), URL: srv.URL + "/repositories"}
+	check := func(want string) {
+		t.Helper()
+		reps, err := ws.Repositories(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := repositorySummary(reps); got != want {
+			t.Errorf("expected repositories %q, but got %q", want, got)
+		}
+	}
+	check("gi=https://github.com/goki/gi")
+
+	// a recently cached list must not hide new repositories
+	page += fmt.Sprintf(entry, "goosi", "Goosi")
+	check("gi=https://github.com/goki/gi goosi=https://github.com/goki/goosi")
+
+	fail = true
+	check("gi=https://github.com/goki/gi goosi=https://github.com/goki/goosi")
+
+	ws.Refresh = true
+	_, err := ws.Repositories(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "status code 503") {
+		t.Errorf("expected a status code error when refreshing, but got %v", err)
+	}
+}
+
+func TestNextLink(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{`<https://api.github.com/repos?page=2>; rel="next", <https://api.github.com/repos?page=5>; rel="last"`, "https://api.github.com/repos?page=2"},
+		{`<https://api.github.com/repos?page=1>; rel="prev", <https://api.github.com/repos?page=3>; rel="next"`, "https://api.github.com/repos?page=3"},
+		{`<https://api.github.com/repos?page=1>; rel="first", <https://api.github.com/repos?page=4>; rel="prev"`, ""},
+		{`invalid`, ""},
+	}
+	for _, test := range tests {
+		if got := nextLink(test.header); got != test.want {
+			t.Errorf("expected next link of %q to be %q, but got %q", test.header, test.want, got)
+		}
+	}
+}
+
+func TestManifestSource(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"gsm.toml": `
+[[Repositories]]
+  Name = "gi"
+
+[[Repositories]]
+  Name = "mytool"
+  Remote = "https://example.com/me/mytool"
+`})
+	ms := &ManifestSource{Config: testSourceConfig(), File: filepath.Join(dir, "gsm.toml")}
+	reps, err := ms.Repositories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "gi=https://github.com/goki/gi mytool=https://example.com/me/mytool"
+	if got := repositorySummary(reps); got != want {
+		t.Errorf("expected repositories %q, but got %q", want, got)
+	}
+}
+
+func TestWorkSource(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.work":            "go 1.21\n\nuse (\n\t./gi\n\t./gi/examples\n\t./goosi\n\t./other\n)\n",
+		"gi/.git/HEAD":       "ref: refs/heads/main\n",
+		"gi/go.mod":          "module goki.dev/gi/v2\n\ngo 1.21\n\nrequire (\n\tgoki.dev/goosi v0.1.0\n\tgolang.org/x/mod v0.14.0\n)\n",
+		"gi/examples/go.mod": "module goki.dev/gi/v2/examples\n\ngo 1.21\n\nrequire goki.dev/gi/v2 v2.0.0\n",
+		"goosi/.git/HEAD":    "ref: refs/heads/main\n",
+		"goosi/go.mod":       "module goki.dev/goosi\n\ngo 1.21\n",
+		"other/go.mod":       "module example.com/other\n\ngo 1.21\n",
+	})
+	chdir(t, dir)
+	ws := &WorkSource{Config: testSourceConfig()}
+	reps, err := ws.Repositories(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "gi=https://github.com/goki/gi goosi=https://github.com/goki/goosi"
+	if got := repositorySummary(reps); got != want {
+		t.Fatalf("expected repositories %q, but got %q", want, got)
+	}
+	gi := reps[0]
+	if gi.VanityURL != "goki.dev/gi/v2" || len(gi.Modules) != 2 || strings.Join(gi.GokiImports, " ") != "goki.dev/goosi" {
+		t.Errorf("expected gi to have the root module goki.dev/gi/v2, 2 modules, and the Goki import goki.dev/goosi, but got %q, %d, and %v", gi.VanityURL, len(gi.Modules), gi.GokiImports)
+	}
+
+	writeFiles(t, dir, map[string]string{"go.work": "go 1.21\n\nuse ./missing\n"})
+	if _, err := ws.Repositories(context.Background()); err == nil {
+		t.Errorf("expected an error for a missing module")
+	}
+}
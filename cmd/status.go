@@ -0,0 +1,163 @@
+// Copyright (c) 2023, The Goki Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+)
+
+// RepositoryStatus is the detailed status of a repository
+// printed by [PrintStatus].
+type RepositoryStatus struct {
+
+	// Repository is the repository
+	Repository *Repository
+
+	// Git is the status of the repository according to
+	// git status, or nil if it could not be determined
+	Git *GitStatus
+
+	// Tag is the latest tag reachable from the current
+	// commit, or "" if there is no such tag
+	Tag string
+
+	// SinceTag is the number of commits since Tag,
+	// or 0 if there is no tag
+	SinceTag int
+
+	// Status is ok if the repository is clean (see [GitStatus.Clean]),
+	// changed if it is not, or failed if its status could not be determined
+	Status Status
+
+	// Error is the error message if the status could not
+	// be determined, or "" if there is no error
+	Error string
+}
+
+// PrintStatus concurrently gets the detailed status of all of the Git repositories
+// in the current directory, except for those ignored by the workspace manifest and
+// those not selected by the selector flags, and prints it in the configured output
+// format. In the text format, it prints a table of the current branch (or the current
+// commit if HEAD is detached), upstream, ahead and behind counts, staged, unstaged,
+// and untracked file counts, stash entries, latest tag, and commits since the latest
+// tag of each repository. In the json and ndjson formats, it prints a
+// [RepositoryStatus] for each repository.
+//
+//grease:cmd -name status
+func PrintStatus(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
+	reps, err := GitRepositories(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	sts := make([]*RepositoryStatus, len(reps))
+	stm := map[*Repository]*RepositoryStatus{}
+	for i, rep := range reps {
+		sts[i] = &RepositoryStatus{Repository: rep}
+		stm[rep] = sts[i]
+	}
+	rs := RunRepositories(ctx, c, reps, func(ctx context.Context, rep *Repository) (Status, error) {
+		return stm[rep].update(ctx)
+	})
+	for i, r := range rs {
+		sts[i].Status = r.Status
+		if r.Err != nil {
+			sts[i].Error = r.Err.Error()
+		}
+	}
+	if IsJSON(c.Format) {
+		err = WriteJSON(os.Stdout, c.Format, sts, sts)
+	} else {
+		err = PrintStatuses(os.Stdout, sts)
+	}
+	if err != nil {
+		return err
+	}
+	return rs.Err()
+}
+
+// update gets the status of the repository and
+// returns its [Status] as described in [RepositoryStatus].
+func (rst *RepositoryStatus) update(ctx context.Context) (Status, error) {
+	git := GitFrom(ctx)
+	dir := filepath.FromSlash(rst.Repository.Dir)
+	st, err := git.Status(ctx, dir)
+	if err != nil {
+		return StatusFailed, fmt.Errorf("error getting status: %w", err)
+	}
+	rst.Git = st
+	if st.Commit != "" { // repositories with no commits have no tags
+		rst.Tag, err = git.Describe(ctx, dir)
+		if err != nil && !errors.Is(err, ErrNoTags) {
+			return StatusFailed, fmt.Errorf("error getting latest tag: %w", err)
+		}
+	}
+	if rst.Tag != "" {
+		msgs, err := git.Log(ctx, dir, rst.Tag)
+		if err != nil {
+			return StatusFailed, fmt.Errorf("error getting commits since %q: %w", rst.Tag, err)
+		}
+		rst.SinceTag = len(msgs)
+	}
+	if st.Clean() {
+		return StatusOK, nil
+	}
+	return StatusChanged, nil
+}
+
+// PrintStatuses prints a table of the given repository statuses
+// to the given writer, as described in [PrintStatus].
+func PrintStatuses(w io.Writer, sts []*RepositoryStatus) error {
+	if len(sts) == 0 {
+		_, err := fmt.Fprintln(w, "No repositories")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tBRANCH\tUPSTREAM\tAHEAD\tBEHIND\tSTAGED\tUNSTAGED\tUNTRACKED\tSTASH\tTAG\tSINCE TAG")
+	for _, rst := range sts {
+		st := rst.Git
+		if st == nil {
+			fmt.Fprintf(tw, "%s\t(%s)\t-\t-\t-\t-\t-\t-\t-\t-\t-\n", rst.Repository.Name, rst.Status)
+			continue
+		}
+		branch := st.Branch
+		switch {
+		case st.Detached():
+			branch = "(detached at " + st.Commit[:min(7, len(st.Commit))] + ")"
+		case st.Commit == "":
+			branch += " (no commits)"
+		}
+		tag, since := "-", "-"
+		if rst.Tag != "" {
+			tag, since = rst.Tag, strconv.Itoa(rst.SinceTag)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\t%s\n", rst.Repository.Name, branch, orDash(st.Upstream),
+			st.Ahead, st.Behind, len(st.Staged)+len(st.Unmerged), len(st.Unstaged), len(st.Untracked), st.Stash, tag, since)
+	}
+	return tw.Flush()
+}
+
+// orDash returns the given string, or "-" if it is "".
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
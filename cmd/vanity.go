@@ -8,41 +8,49 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 	"text/template"
 
 	"github.com/iancoleman/strcase"
-	"goki.dev/xe"
 )
 
 type newVanityTmplData struct {
-	Name     string
-	RepoName string
-	Title    string
+	Title         string
+	RepositoryURL string
+	VanityURL     string
 }
 
 var newVanityTmpl = template.Must(template.New("newVanity").Parse(
 	`+++
 title = '{{.Title}}'
-repo = 'https://github.com/goki/{{.RepoName}}'
-packages = ['goki.dev/{{.Name}}']
+repo = '{{.RepositoryURL}}'
+packages = ['{{.VanityURL}}']
 +++
 `))
 
 // NewVanity makes a new vanity import URL page for the config
-// repository name. It should only be called in the root directory
-// of the goki.github.io repository. It commits and pushes the page.
+// repository name, using the configured vanity import path prefix
+// and Git host. It should only be called in the root directory of
+// the vanity import site repository (eg: goki.github.io). It commits
+// and pushes the page.
 func NewVanity(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
 	b := bytes.Buffer{}
-	d := newVanityTmplData{Name: c.Repository, RepoName: c.Repository, Title: strcase.ToCamel(c.Repository)}
 	// we cut any later parts of the repository name (major version suffixes,
 	// submodules, etc), but leave them in the module name
-	if before, _, has := strings.Cut(c.Repository, "/"); has {
-		d.RepoName = before
-		d.Title = strcase.ToCamel(d.RepoName)
+	repoName, _, _ := strings.Cut(c.Repository, "/")
+	d := newVanityTmplData{
+		Title:         strcase.ToCamel(repoName),
+		RepositoryURL: c.RepositoryURL(repoName),
+		VanityURL:     path.Join(c.Vanity, c.Repository),
 	}
-	err := newVanityTmpl.Execute(&b, d)
+	err = newVanityTmpl.Execute(&b, d)
 	if err != nil {
 		return fmt.Errorf("programmer error: error executing vanity URL file template: %w", err)
 	}
@@ -56,13 +64,14 @@ func NewVanity(c *Config) error { //gti:add
 	if err != nil {
 		return fmt.Errorf("error writing to _index.md file for vanity URL: %w", err)
 	}
-	err = xe.Run("git", "add", fname)
+	git := GitFrom(ctx)
+	err = git.Add(ctx, ".", fname)
 	if err != nil {
 		return fmt.Errorf("error adding to git: %w", err)
 	}
-	err = xe.Run("git", "commit", "-am", "added "+c.Repository)
+	err = git.Commit(ctx, ".", "added "+c.Repository)
 	if err != nil {
 		return err
 	}
-	return xe.Run("git", "push")
+	return git.Push(ctx, ".")
 }
@@ -8,34 +8,70 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"goki.dev/glop/dirs"
 	"goki.dev/xe"
 )
 
 // Work adds all of the Go modules in the current directory to the go.work
-// file in the current directory.
+// file in the current directory, except for those skipped by the workspace manifest
+// and those in repositories not selected by the selector flags.
 func Work(c *Config) error { //gti:add
+	ctx, cancel, err := c.Context()
+	if err != nil {
+		return err
+	}
+	defer cancel()
+	m, err := LoadManifest(c.Manifest)
+	if err != nil {
+		return err
+	}
 	ex, err := dirs.FileExists("go.work")
 	if err != nil {
 		return err
 	}
 	if !ex {
-		err := xe.Run("go", "work", "init")
+		err := Run(ctx, xe.Major(), "go", "work", "init")
 		if err != nil {
 			return err
 		}
 	}
-	return fs.WalkDir(os.DirFS("."), ".", func(path string, d fs.DirEntry, err error) error {
+	sel, err := NewSelection(ctx, c, m)
+	if err != nil {
+		return err
+	}
+	modDirs := []string{}
+	err = fs.WalkDir(os.DirFS("."), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if skipModuleDir(path, d) {
+			return fs.SkipDir
+		}
 		if d.Name() != "go.mod" {
 			return nil
 		}
 		dir := filepath.Dir(path)
-		// TODO: figure out a more sustainable solution to this temporary workaround
-		if dir == "gipy" || dir == "goki.github.io" || dir == "android-go" || strings.Contains(dir, "internal") {
+		if m.SkipWork(dir) {
 			return nil
 		}
-		return xe.Run("go", "work", "use", dir)
+		modDirs = append(modDirs, dir)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	modDirs, err = SelectItems(ctx, sel, modDirs, func(dir string) string { return findRepositoryDir(filepath.ToSlash(dir)) }, func(dir string) string {
+		return filepath.FromSlash(findRepositoryDir(filepath.ToSlash(dir)))
 	})
+	if err != nil {
+		return err
+	}
+	for _, dir := range modDirs {
+		err := Run(ctx, xe.Major(), "go", "work", "use", dir)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -7,6 +7,7 @@ require (
 	goki.dev/glop v0.1.9
 	goki.dev/grease v0.8.44
 	goki.dev/grog v0.0.27
+	goki.dev/grows v0.3.31
 	goki.dev/gti v0.1.32
 	goki.dev/ordmap v0.5.10
 	goki.dev/xe v0.0.28
@@ -28,7 +29,6 @@ require (
 	goki.dev/cam v0.9.49 // indirect
 	goki.dev/colors v0.8.44 // indirect
 	goki.dev/enums v0.9.56 // indirect
-	goki.dev/grows v0.3.31 // indirect
 	goki.dev/laser v0.1.34 // indirect
 	goki.dev/mat32/v2 v2.0.0-dev0.0.28 // indirect
 	golang.org/x/image v0.14.0 // indirect
@@ -5,11 +5,21 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"goki.dev/grease"
+	"goki.dev/grog"
 	"goki.dev/gsm/cmd"
 )
 
 func main() {
 	opts := grease.DefaultOptions("gsm", "GSM", "CLI and GUI tools for maintaining the source code of Goki itself (Goki Source Management)")
-	grease.Run(opts, &cmd.Config{}, cmd.Clone, cmd.Pull, cmd.Changed, cmd.Release, cmd.Work, cmd.InstallTools, cmd.Gendex, cmd.NewVanity, cmd.MakeIOSFramework)
+	// we handle errors ourselves so that we can use meaningful exit codes
+	opts.Fatal = false
+	err := grease.Run(opts, &cmd.Config{}, cmd.Clone, cmd.Pull, cmd.Changed, cmd.Release, cmd.Work, cmd.InstallTools, cmd.Gendex, cmd.NewVanity, cmd.MakeIOSFramework, cmd.Graph, cmd.Dependents, cmd.List, cmd.Exec, cmd.PrintStatus, cmd.Changelog, cmd.Diff, cmd.Apply, cmd.Commit, cmd.Push)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, grog.ErrorColor(err.Error()))
+		os.Exit(cmd.ExitCode(err))
+	}
 }